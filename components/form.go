@@ -0,0 +1,21 @@
+package components
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/htmdsl"
+)
+
+// Form is an html form that includes the csrf token of the session, so it can
+// be submitted without javascript. Use it for any form that changes state.
+type Form []any
+
+var _ html.HTML = (*Form)(nil)
+
+func (children Form) Render(ctx context.Context) html.RenderedHTML {
+	return append(html.Form(slices.Clip(children)),
+		html.Input{"type": "hidden", "name": "csrf_token", "value": access.CSRFToken(ctx)},
+	).Render(ctx)
+}
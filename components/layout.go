@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
-	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
 	"github.com/Gardego5/htmdsl"
 	"github.com/Gardego5/htmdsl/util"
 )
@@ -48,15 +47,10 @@ func (header Header) Render(ctx context.Context) html.RenderedHTML {
 		html.Nav{
 			html.Class("relative -left-1 mx-auto mb-1 flex justify-end max-w-3xl shrink items-center gap-4 px-3 text-sm after:absolute after:-left-[100%] after:-z-10 after:h-[1px] after:w-[1000vw] after:bg-slate-300 dark:after:bg-slate-500 md:text-base *:bg-zinc-50 *:dark:bg-zinc-950 *:px-2"),
 			func(ctx context.Context) any {
-				svc := access.Get[currentuser.Service](ctx)
-				session := access.Session(ctx)
-				if user, err := svc.GetUserBySession(ctx, session); err == nil {
-					identifier := user.GetLogin()
-					if identifier == "" {
-						identifier = "user"
-					}
+				if session := access.SessionData(ctx); session != nil && session.User != "" {
+					identifier := session.User
 					return html.Fragment{
-						html.Form{html.Attrs{"method": "POST", "action": "/auth/signout"},
+						Form{html.Attrs{"method": "POST", "action": "/auth/signout"},
 							html.Button{html.Class("cursor-pointer"), "signout"},
 						},
 
@@ -105,15 +99,20 @@ get menuStyle() {
 									},
 									html.Li{html.A{html.Attrs{"href": "/admin/user"}, identifier}},
 									html.Li{html.A{html.Attrs{"href": "/admin/messages"}, "messages"}},
+									html.Li{html.A{html.Attrs{"href": "/admin/sessions"}, "sessions"}},
+									html.Li{html.A{html.Attrs{"href": "/admin/policies"}, "policies"}},
+									html.Li{html.A{html.Attrs{"href": "/admin/caches"}, "caches"}},
+									html.Li{html.A{html.Attrs{"href": "/admin/audit"}, "audit"}},
+									html.Li{html.A{html.Attrs{"href": "/admin/tokens"}, "tokens"}},
+									html.Li{Form{html.Attrs{"method": "POST", "action": "/auth/signout/everywhere"},
+										html.Button{html.Class("cursor-pointer"), "signout everywhere"},
+									}},
 								},
 							},
 						},
 					}
 				} else {
-					return html.Form{html.Class("mr-auto"), html.Attrs{
-						"method": "POST",
-						"action": "/auth/signin",
-					}, html.Button{"signin"}}
+					return html.A{html.Class("mr-auto"), html.Attrs{"href": "/auth/signin"}, "signin"}
 				}
 			},
 
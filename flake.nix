@@ -138,7 +138,9 @@
               mv share build
             '';
             ldflags = [ ];
-            vendorHash = "sha256-xpN/Tm7Jylln0t2gjRc4LHEYtKJV2aTtlUFWuMEjVmw=";
+            # only the site, utils/testdb brings in a sqlite driver for tests.
+            subPackages = [ "." ];
+            vendorHash = "sha256-gIYOgPvd3tjd2XcwoDv76xt5d1Sf0G42ngEH1eujARs=";
             tags = [ "fonts" "static" ];
           };
           cacheId = builtins.hashString "md5" (builtins.toJSON module);
@@ -9,20 +9,32 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Gardego5/garrettdavis.dev/resource/initialize"
 	"github.com/Gardego5/garrettdavis.dev/resource/middleware"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
 	"github.com/Gardego5/garrettdavis.dev/routes"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
 	"github.com/Gardego5/garrettdavis.dev/service/blog"
 	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/service/health"
 	"github.com/Gardego5/garrettdavis.dev/service/messages"
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
 	"github.com/Gardego5/garrettdavis.dev/service/object"
+	"github.com/Gardego5/garrettdavis.dev/service/policies"
 	"github.com/Gardego5/garrettdavis.dev/service/presentations"
 	"github.com/Gardego5/garrettdavis.dev/service/resume"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/service/tokens"
 	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/lifecycle"
 	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/garrettdavis.dev/utils/ratelimit"
 	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
 	"github.com/Gardego5/goutils/env"
 	"github.com/go-playground/validator/v10"
@@ -31,34 +43,88 @@ import (
 
 var (
 	Env = utils.Must(env.Load[struct {
-		ApplicationSecret string        `env:"APPLICATION_SECRET" validate:"required"`
-		BaseUrl           string        `env:"BASE_URL=https://garrettdavis.dev" validate:"required"`
-		GithubOauthId     string        `env:"GITHUB_OAUTH_CLIENT_ID" validate:"required"`
-		GithubOauthSecret string        `env:"GITHUB_OAUTH_CLIENT_SECRET" validate:"required"`
-		Host              string        `env:"HOST=0.0.0.0" validate:"required"`
-		ImagesBucket      string        `env:"IMAGES_BUCKET" validate:"required"`
-		LogLevel          slog.LevelVar `env:"LOG_LEVEL=INFO"`
-		Port              int           `env:"PORT=8080" validate:"required"`
-		RedisUrl          string        `env:"REDIS_URL" validate:"required"`
-		TursoAuthToken    string        `env:"TURSO_AUTH_TOKEN" validate:"required"`
-		TursoDatabaseUrl  string        `env:"TURSO_DATABASE_URL" validate:"required"`
+		AccessLogSample   float64                   `env:"ACCESS_LOG_SAMPLE=1" validate:"min=0,max=1"` // share of requests that are logged
+		ApplicationSecret string                    `env:"APPLICATION_SECRET" validate:"required"`
+		AuthProviders     string                    `env:"AUTH_PROVIDERS=github" validate:"required"`
+		BehindFly         bool                      `env:"BEHIND_FLY=false"` // whether requests come through fly's proxy, which forwards the client's address
+		BaseUrl           string                    `env:"BASE_URL=https://garrettdavis.dev" validate:"required"`
+		CacheBackend      initialize.CacheBackend   `env:"CACHE_BACKEND=redis" validate:"oneof=redis memory"`
+		CacheL1Size       int                       `env:"CACHE_L1_SIZE=0" validate:"min=0"`
+		CacheSize         int                       `env:"CACHE_SIZE=10000" validate:"min=0"`
+		GithubOauthId     string                    `env:"GITHUB_OAUTH_CLIENT_ID" validate:"required"`
+		GithubOauthSecret string                    `env:"GITHUB_OAUTH_CLIENT_SECRET" validate:"required"`
+		GithubRevoke      bool                      `env:"GITHUB_REVOKE_TOKENS=true"`
+		Host              string                    `env:"HOST=0.0.0.0" validate:"required"`
+		ImagesBucket      string                    `env:"IMAGES_BUCKET" validate:"required"`
+		LogLevel          slog.LevelVar             `env:"LOG_LEVEL=INFO"`
+		Port              int                       `env:"PORT=8080" validate:"required"`
+		ShutdownDrain     int                       `env:"SHUTDOWN_DRAIN=6" validate:"min=0"`    // seconds requests are still served for after a signal, at least the interval readiness is checked at
+		ShutdownTimeout   int                       `env:"SHUTDOWN_TIMEOUT=25" validate:"min=1"` // seconds stopping may take in total
+		TracesExporter    initialize.TracesExporter `env:"OTEL_TRACES_EXPORTER=none" validate:"oneof=otlp console none"`
+		TursoAuthToken    string                    `env:"TURSO_AUTH_TOKEN" validate:"required"`
+		TursoDatabaseUrl  string                    `env:"TURSO_DATABASE_URL" validate:"required"`
 	}]())
 
-	Validate = validator.New()
-	Logger   = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &Env.LogLevel}))
-	DB       = initialize.NewDB(Env.TursoDatabaseUrl, Env.TursoAuthToken)
-	Redis    = initialize.NewRedis(Env.RedisUrl)
-	Enforcer = utils.Must(initialize.Enforcer(DB, Logger))
-	Caches   = initialize.Caches(Redis)
-	Block    = symetric.Block(Env.ApplicationSecret)
+	Validate  = validator.New()
+	Logger    = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: &Env.LogLevel}))
+	Lifecycle = lifecycle.New(Logger)
+	Tracing   = utils.Must(initialize.Tracing(context.Background(), Env.TracesExporter, CacheID))
+	DB        = initialize.NewDB(Env.TursoDatabaseUrl, Env.TursoAuthToken)
+	// REDIS_URL is only required by the redis cache backend, and env can't
+	// load a variable that may be missing, so it's read directly.
+	Redis    = initialize.NewRedis(os.Getenv("REDIS_URL"), Env.CacheBackend)
+	Metrics  = metrics.New()
+	Enforcer = utils.Must(initialize.Enforcer(DB, Logger, Metrics))
+	// APPLICATION_SECRET may be a comma separated list to rotate secrets, the
+	// first one is used to encrypt.
+	Keys                = utils.Must(symetric.NewKeyring(strings.Split(Env.ApplicationSecret, ",")...))
+	Caches, CloseCaches = initialize.Caches(Redis, initialize.CacheConfig{
+		Backend: Env.CacheBackend, Size: Env.CacheSize, L1Size: Env.CacheL1Size,
+		AEAD: Keys.AEAD("cache"), Metrics: Metrics})
+	Limiter       = initialize.RateLimiter(Redis, Env.CacheBackend, Logger)
+	SessionCookie = cookie.NewSession(Keys)
+	StateCookie   = cookie.NewState(Keys)
+	// AUTH_PROVIDERS is a comma separated list, in the order they're offered.
+	AuthProviders = strings.Split(strings.ReplaceAll(Env.AuthProviders, " ", ""), ",")
+	FakeIssuer    = utils.Must(initialize.FakeIssuer(AuthProviders, Env.BaseUrl))
 
 	// services
+	Audit = utils.Must(audit.New(DB))
+	Auth  = utils.Must(initialize.Auth(initialize.AuthConfig{
+		Providers: AuthProviders, BaseUrl: Env.BaseUrl,
+		GithubClientId: Env.GithubOauthId, GithubClientSecret: Env.GithubOauthSecret,
+		Users: CurrentUser}))
 	Blog          = blog.New()
 	CurrentUser   = currentuser.New(Caches)
 	ImagesBucket  = utils.Must(object.New(context.Background(), Env.ImagesBucket, Logger))
-	Messages      = utils.Must(messages.New(DB))
+	Messages      = utils.Must(messages.New(DB, Metrics))
+	Policies      = utils.Must(policies.New(DB, Enforcer))
+	PolicyWatcher = policyWatcher()
 	Presentations = presentations.New()
 	Resume        = resume.New(Validate)
+	Revoker       = initialize.Revoker(Env.GithubRevoke, Env.GithubOauthId, Env.GithubOauthSecret)
+	Sessions      = session.New(Caches, 5*time.Hour)
+	Tokens        = utils.Must(tokens.New(DB))
+
+	// readiness is checked by every proxy, the results are shared for a
+	// second so that doesn't load the dependencies.
+	Health = health.New(Lifecycle.Stopping(), 2*time.Second, time.Second, healthChecks(map[string]health.Check{
+		"db": DB.PingContext,
+		// these load lazily, so checking them loads them before any request
+		// needs them.
+		"blog": func(context.Context) error {
+			if len(Blog.List()) == 0 {
+				return errors.New("no posts")
+			}
+			return nil
+		},
+		"presentations": func(context.Context) error {
+			if len(Presentations.Presentations()) == 0 {
+				return errors.New("no presentations")
+			}
+			return nil
+		},
+	}), Logger)
 
 	// these are assets / configuration included at build time
 	//go:embed build
@@ -68,38 +134,111 @@ var (
 	StaticPrefix = fmt.Sprintf("/static/%s", CacheID)
 
 	Mux = mux.NewServeMux(func(m *mux.ServeMux) {
+		// messages can be sent with the form or the api, they share a limit so
+		// using both doesn't allow twice as many.
+		contact := middleware.RateLimit(Limiter, "contact",
+			ratelimit.Limit{Rate: 5, Period: time.Hour, Burst: 3}, middleware.RateLimitIP)
+
 		m.Group("/admin", func(m *mux.ServeMux) {
-			m.Group("/messages", func(m *mux.ServeMux) {
-				h := routes.NewAdminMessages(Messages)
+			m.Group("/audit", func(m *mux.ServeMux) {
+				h := routes.NewAdminAudit(Audit)
+				m.Handle("GET", mux.HandlerFunc(h.GET))
+				m.Handle("GET /csv", mux.HandlerFunc(h.GetCSV))
+			})
+			m.Group("/caches", func(m *mux.ServeMux) {
+				h := routes.NewAdminCaches(Caches)
 				m.HandleFunc("GET", h.GET)
-				m.HandleFunc("DELETE /{id}", h.DELETE)
+				m.Handle("DELETE /{prefix}", mux.HandlerFunc(h.DELETE))
+			})
+			m.Group("/messages", func(m *mux.ServeMux) {
+				h := routes.NewAdminMessages(Messages, Audit)
+				m.Handle("GET", mux.HandlerFunc(h.GET))
+				m.Handle("DELETE /{id}", mux.HandlerFunc(h.DELETE))
+			})
+			m.Group("/policies", func(m *mux.ServeMux) {
+				h := routes.NewAdminPolicies(Policies, PolicyWatcher, Audit, Validate)
+				m.Handle("GET", mux.HandlerFunc(h.GET))
+				m.HandleFunc("GET /status", h.GetStatus)
+				m.Handle("POST", mux.HandlerFunc(h.POST))
+				m.Handle("PUT", mux.HandlerFunc(h.PUT))
+				m.Handle("DELETE", mux.HandlerFunc(h.DELETE))
+				m.Handle("POST /roles", mux.HandlerFunc(h.PostRoles))
+				m.Handle("DELETE /roles", mux.HandlerFunc(h.DeleteRoles))
+				m.Handle("POST /test", mux.HandlerFunc(h.PostTest))
 			})
-			m.Handle("GET /user", routes.NewAdminUser(CurrentUser))
+			m.Group("/sessions", func(m *mux.ServeMux) {
+				h := routes.NewAdminSessions(Sessions)
+				m.Handle("GET", mux.HandlerFunc(h.GET))
+				m.Handle("DELETE /{id}", mux.HandlerFunc(h.DELETE))
+			})
+			m.Group("/tokens", func(m *mux.ServeMux) {
+				h := routes.NewAdminTokens(Tokens, Audit, Validate)
+				m.Handle("GET", mux.HandlerFunc(h.GET))
+				m.Handle("POST", mux.HandlerFunc(h.POST))
+				m.Handle("DELETE /{id}", mux.HandlerFunc(h.DELETE))
+			})
+			m.Handle("GET /user", mux.HandlerFunc(routes.NewAdminUser(CurrentUser).GET))
 			m.Group("/coffee", func(m *mux.ServeMux) {
 				h := routes.NewAdminCoffee(ImagesBucket)
 				m.HandleFunc("GET", h.GetAdminCoffee)
 			})
 		},
-			middleware.Authorization(Logger, Enforcer, CurrentUser))
+			middleware.Authorization(Logger, Enforcer, Sessions, CurrentUser, Tokens, Audit, Env.BaseUrl),
+			// limited after authorization, so requests with a token count
+			// against its user.
+			middleware.RateLimit(Limiter, "admin", ratelimit.PerMinute(120, 30), middleware.RateLimitUser))
+
+		m.Group("/api/v1", func(m *mux.ServeMux) {
+			authorized := middleware.Authorization(Logger, Enforcer, Sessions, CurrentUser, Tokens, Audit, Env.BaseUrl)
+			// requests count against their user, so the limit comes after
+			// authorization where there is one.
+			limit := middleware.RateLimit(Limiter, "api", ratelimit.PerMinute(60, 20), middleware.RateLimitUser)
+			m.Group("/messages", func(m *mux.ServeMux) {
+				h := routes.NewAPIMessages(Messages, Audit)
+				m.Handle("GET", mux.HandlerFunc(h.GET), authorized, limit)
+				m.Handle("POST", mux.HandlerFunc(h.POST), limit, contact)
+				m.Handle("DELETE /{id}", mux.HandlerFunc(h.DELETE), authorized, limit)
+			})
+			m.Use(func(m *mux.ServeMux) {
+				m.Group("/posts", func(m *mux.ServeMux) {
+					h := routes.NewAPIPosts(Blog)
+					m.HandleFunc("GET", h.GET)
+					m.Handle("GET /{slug}", mux.HandlerFunc(h.GetPost))
+				})
+				m.Group("/presentations", func(m *mux.ServeMux) {
+					h := routes.NewAPIPresentations(Presentations)
+					m.HandleFunc("GET", h.GET)
+					m.Handle("GET /{slug}", mux.HandlerFunc(h.GetPresentation))
+				})
+				m.Handle("GET /openapi.json", utils.Must(routes.NewAPIDocs(Env.BaseUrl)))
+				m.Handle("GET /", mux.HandlerFunc(routes.APINotFound))
+			}, limit)
+		}, middleware.API)
+
+		m.Handle("GET /metrics", Metrics,
+			middleware.Authorization(Logger, Enforcer, Sessions, CurrentUser, Tokens, Audit, Env.BaseUrl))
 
 		m.Group("/auth", func(m *mux.ServeMux) {
-			m.Handle("GET /callback", routes.NewAuthCallback(
-				Env.GithubOauthId, Env.GithubOauthSecret,
-				Validate, Block, CurrentUser, Caches, Enforcer, Env.BaseUrl))
-			m.Group("/signin", func(m *mux.ServeMux) {
-				h := routes.NewAuthSignin(Env.GithubOauthId, Block, Env.BaseUrl)
-				m.HandleFunc("GET", h.GET)
-				m.HandleFunc("POST", h.POST)
+			m.Handle("GET /{provider}/callback", mux.HandlerFunc(routes.NewAuthCallback(
+				Validate, Auth, StateCookie, SessionCookie, Sessions, Enforcer, Audit, Env.BaseUrl).GET))
+			{
+				h := routes.NewAuthSignin(Auth, StateCookie, Env.BaseUrl)
+				m.HandleFunc("GET /signin", h.GET)
+				m.Handle("POST /{provider}/signin", mux.HandlerFunc(h.POST))
+			}
+			m.Group("/signout", func(m *mux.ServeMux) {
+				h := routes.NewAuthSignout(Sessions, Revoker, Audit)
+				m.Handle("POST", mux.HandlerFunc(h.POST))
+				m.Handle("POST /everywhere", mux.HandlerFunc(h.PostEverywhere))
 			})
-			m.Handle("POST /signout", routes.NewAuthSignout())
-		})
+		}, middleware.RateLimit(Limiter, "auth", ratelimit.PerMinute(10, 10), middleware.RateLimitIP))
 
 		m.Handle("GET /blog/{slug}", routes.NewBlog(Blog))
 
 		m.Group("/contact", func(m *mux.ServeMux) {
 			h := routes.NewContact(Messages, Validate)
 			m.HandleFunc("GET", h.GET)
-			m.HandleFunc("POST", h.POST)
+			m.Handle("POST", mux.HandlerFunc(h.POST), contact)
 		})
 
 		m.Handle("GET /presentations/{slug}", routes.NewPresentations(Presentations))
@@ -119,7 +258,10 @@ var (
 		m.Handle("GET /{$}", routes.NewIndex(Blog))
 		m.HandleFunc("GET /", routes.Get404)
 	},
-		middleware.LoggerAndSessions(Logger, true),
+		middleware.ClientIP(Env.BehindFly),
+		middleware.Metrics(Metrics),
+		middleware.Tracing,
+		middleware.LoggerAndSessions(Logger, Env.AccessLogSample, SessionCookie),
 		middleware.TrailingSlash,
 		middleware.Inject(
 			middleware.Syringe(Blog),
@@ -127,49 +269,104 @@ var (
 			middleware.Syringe(Messages),
 			middleware.Syringe(Presentations),
 			middleware.Syringe(Resume),
+			middleware.Syringe(Sessions),
 			middleware.Syringe(Validate),
 			middleware.Syringe(utils.Ptr(render.StaticPathPrefix(StaticPrefix))),
 			middleware.Syringe(formam.NewDecoder(&formam.DecoderOptions{TagName: "q"})),
 		),
+		middleware.Recover(Metrics),
+		mux.OnError(render.ServeError),
+		middleware.Sessions(Sessions, SessionCookie),
+		middleware.CSRF(Sessions, Env.BaseUrl),
 	)
 )
 
+// policyWatcher syncs policy changes between machines through redis. Without
+// redis there's only this machine, and nothing to sync.
+func policyWatcher() *policies.Watcher {
+	if Redis == nil {
+		return nil
+	}
+	return utils.Must(policies.NewWatcher(Redis, Enforcer, Logger))
+}
+
+// healthChecks adds the checks of redis, and syncing policies through it, to
+// checks when there is redis.
+func healthChecks(checks map[string]health.Check) map[string]health.Check {
+	if Redis == nil {
+		return checks
+	}
+
+	checks["redis"] = func(ctx context.Context) error { return Redis.Ping(ctx).Err() }
+	// the last good policy is kept when reloading fails, but it may be out of
+	// date.
+	checks["policy"] = func(context.Context) error {
+		if status := PolicyWatcher.Status(); status.Failures > 0 {
+			return fmt.Errorf("%d reloads failed: %s", status.Failures, status.Error)
+		}
+		return nil
+	}
+	return checks
+}
+
 func main() {
 	Logger := Logger.With("function", "main")
 
-	defer func() {
-		if err := errors.Join(
-			DB.Close(),
-			Messages.Close(),
-		); err != nil {
-			Logger.Error("Error cleaning up resources", "error", err)
-			os.Exit(1)
-		}
-	}()
+	// health checks are answered before any middleware, so they don't log or
+	// start sessions.
+	root := http.NewServeMux()
+	{
+		h := routes.NewHealth(Health)
+		root.HandleFunc("GET /healthz", h.GetHealthz)
+		root.HandleFunc("GET /readyz", h.GetReadyz)
+	}
+	if FakeIssuer != nil {
+		Logger.Warn("The fake auth provider is enabled, anyone can sign in as anyone")
+		root.Handle(initialize.FakeIssuerPath+"/", FakeIssuer)
+	}
+	root.Handle("/", Mux)
+	Server := initialize.Server(fmt.Sprintf("%s:%d", Env.Host, Env.Port), root, Logger)
 
-	tickPolicy := time.NewTicker(time.Minute)
+	// these stop in reverse, so requests finish before anything they use is
+	// closed.
+	Lifecycle.Append(lifecycle.Hook{Name: "tracing", Stop: Tracing.Shutdown})
+	Lifecycle.Closer("db", DB.Close)
+	if Redis != nil {
+		Lifecycle.Closer("redis", Redis.Close)
+	}
+	Lifecycle.Closer("caches", CloseCaches)
+	Lifecycle.Closer("audit", Audit.Close)
+	Lifecycle.Closer("messages", Messages.Close)
+	Lifecycle.Closer("tokens", Tokens.Close)
+	if PolicyWatcher != nil {
+		Lifecycle.Go("policy watcher", func(ctx context.Context) error {
+			PolicyWatcher.Run(ctx)
+			return nil
+		})
+	}
+	Lifecycle.Serve("server", Server, time.Duration(Env.ShutdownDrain)*time.Second)
 
-	chServer := make(chan struct{})
-	go func() {
-		addr := fmt.Sprintf("%s:%d", Env.Host, Env.Port)
-		if err := http.ListenAndServe(addr, Mux); err != nil {
-			Logger.Error("Error starting server", "error", err)
-		}
-		chServer <- struct{}{}
-	}()
-
-	for {
-		select {
-		case <-tickPolicy.C:
-			if err := Enforcer.LoadPolicy(); err != nil {
-				Logger.Error("Error loading policy", "error", err)
-				return
-			} else {
-				Logger.Info("Policy reloaded")
-			}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		case <-chServer:
-			return
-		}
+	failed := Lifecycle.Start(ctx)
+	if failed == nil {
+		failed = Lifecycle.Wait(ctx)
+	}
+	// a second signal kills the process, rather than waiting.
+	stop()
+
+	if failed != nil {
+		Logger.Error("Stopping after a failure", "error", failed)
+	} else {
+		Logger.Info("Stopping after a signal")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Env.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := Lifecycle.Stop(ctx); err != nil || failed != nil {
+		Logger.Error("Stopped uncleanly", "error", err)
+		os.Exit(1)
 	}
+	Logger.Info("Stopped")
 }
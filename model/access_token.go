@@ -0,0 +1,24 @@
+//go:generate msgp
+package model
+
+const (
+	// ProviderGithub is the name of the github oauth provider.
+	ProviderGithub = "github"
+	// ProviderGitlab is the name of the gitlab oauth provider.
+	ProviderGitlab = "gitlab"
+)
+
+type AccessToken struct {
+	// Provider is the name of the oauth provider that issued the token.
+	Provider    string `json:"provider" msg:"provider"`
+	AccessToken string `json:"access_token" validate:"required"`
+	Scope       string `json:"scope"`
+	TokenType   string `json:"token_type" validate:"required"`
+}
+
+// IsGithub reports whether the token was issued by github. Tokens stored
+// before other providers were supported don't have a provider, and are
+// always from github.
+func (t *AccessToken) IsGithub() bool {
+	return t.Provider == "" || t.Provider == ProviderGithub
+}
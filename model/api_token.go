@@ -0,0 +1,95 @@
+//go:generate msgp
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type TokenScope string
+
+const (
+	// TokenScopeRead allows requests that don't change anything.
+	TokenScopeRead TokenScope = "read"
+	// TokenScopeWrite allows requests that do.
+	TokenScopeWrite TokenScope = "write"
+)
+
+var TokenScopes = []TokenScope{TokenScopeRead, TokenScopeWrite}
+
+// Scopes are stored as a space separated list.
+type Scopes []TokenScope
+
+func (s *Scopes) Scan(value any) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected string as internal db value, got %T", value)
+	}
+	*s = Scopes{}
+	for _, scope := range strings.Fields(str) {
+		*s = append(*s, TokenScope(scope))
+	}
+	return nil
+}
+
+func (s Scopes) Value() (driver.Value, error) {
+	strs := make([]string, len(s))
+	for i, scope := range s {
+		strs[i] = string(scope)
+	}
+	return strings.Join(strs, " "), nil
+}
+
+// Allows reports whether the scopes allow a request with method.
+func (s Scopes) Allows(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return slices.Contains(s, TokenScopeRead) || slices.Contains(s, TokenScopeWrite)
+	default:
+		return slices.Contains(s, TokenScopeWrite)
+	}
+}
+
+// Groups are stored as a space separated list too.
+type Groups []string
+
+func (g *Groups) Scan(value any) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected string as internal db value, got %T", value)
+	}
+	*g = strings.Fields(str)
+	return nil
+}
+
+func (g Groups) Value() (driver.Value, error) {
+	return strings.Join(g, " "), nil
+}
+
+// APIToken is a personal access token, which acts as the subject that
+// created it, limited to its scopes. Only a hash of the token is kept.
+type APIToken struct {
+	ID       int    `db:"id"`
+	Name     string `db:"name" validate:"required,max=100"`
+	Provider string `db:"provider"`
+	Owner    string `db:"owner"`
+	Prefix   string `db:"prefix"`
+	Hash     string `db:"hash"`
+	Scopes   Scopes `db:"scopes" validate:"min=1,dive,oneof=read write"`
+	// Groups are the owner's groups when the token was created. Tokens
+	// can't look them up again, since they don't have the owner's oauth
+	// token, so they keep roles given to a group the owner has since left,
+	// until the token is revoked or expires.
+	Groups     Groups   `db:"groups"`
+	ExpiresAt  NullTime `db:"expires_at"`
+	LastUsedAt NullTime `db:"last_used_at"`
+	RevokedAt  NullTime `db:"revoked_at"`
+	CreatedAt  Time     `db:"created_at"`
+}
+
+// Subject is who the token acts as.
+func (t *APIToken) Subject() Subject {
+	return Subject{Provider: t.Provider, User: t.Owner, Groups: t.Groups}
+}
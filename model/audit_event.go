@@ -0,0 +1,51 @@
+//go:generate msgp
+package model
+
+type AuditAction string
+
+const (
+	AuditSignIn            AuditAction = "auth.signin"
+	AuditSignOut           AuditAction = "auth.signout"
+	AuditSignOutEverywhere AuditAction = "auth.signout_everywhere"
+	AuditAccess            AuditAction = "authz.access"
+	AuditDeleteMessage     AuditAction = "admin.messages.delete"
+	AuditCreateToken       AuditAction = "admin.tokens.create"
+	AuditRevokeToken       AuditAction = "admin.tokens.revoke"
+	AuditAssignRole        AuditAction = "admin.roles.assign"
+	AuditUnassignRole      AuditAction = "admin.roles.unassign"
+)
+
+var AuditActions = []AuditAction{
+	AuditSignIn,
+	AuditSignOut,
+	AuditSignOutEverywhere,
+	AuditAccess,
+	AuditDeleteMessage,
+	AuditCreateToken,
+	AuditRevokeToken,
+	AuditAssignRole,
+	AuditUnassignRole,
+}
+
+type AuditResult string
+
+const (
+	AuditSuccess AuditResult = "success"
+	AuditFailure AuditResult = "failure"
+	AuditDenied  AuditResult = "denied"
+)
+
+var AuditResults = []AuditResult{AuditSuccess, AuditFailure, AuditDenied}
+
+// AuditEvent records who did what, to what, and how it went. Actor is
+// empty for anonymous users.
+type AuditEvent struct {
+	ID        int         `db:"id"`
+	Actor     string      `db:"actor"`
+	Action    AuditAction `db:"action"`
+	Target    string      `db:"target"`
+	IP        string      `db:"ip"`
+	RequestID string      `db:"request_id"`
+	Result    AuditResult `db:"result"`
+	CreatedAt Time        `db:"created_at"`
+}
@@ -0,0 +1,8 @@
+//go:generate msgp
+package model
+
+// GithubGroups are the organizations and teams a github user is a member
+// of, as they're named in policies.
+type GithubGroups struct {
+	Groups []string `msg:"groups"`
+}
@@ -0,0 +1,8 @@
+CREATE TABLE policy_changes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  actor TEXT NOT NULL,
+  action TEXT NOT NULL,
+  old_rule TEXT,
+  new_rule TEXT,
+  created_at TEXT NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP TRIGGER audit_events_no_delete;
+DROP TRIGGER audit_events_no_update;
+DROP INDEX audit_events_created_at;
+DROP TABLE audit_events;
@@ -0,0 +1,2 @@
+DROP INDEX api_tokens_owner;
+DROP TABLE api_tokens;
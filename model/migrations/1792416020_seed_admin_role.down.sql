@@ -0,0 +1,5 @@
+DELETE FROM casbin_rule WHERE p_type = 'g' AND v0 = 'Gardego5' AND v1 = 'role:admin';
+
+DELETE FROM casbin_rule
+WHERE p_type = 'p' AND v0 = 'hasRole(r.sub, "admin")' AND v1 = '/admin/*'
+  AND v2 IN ('GET', 'POST', 'PUT', 'DELETE');
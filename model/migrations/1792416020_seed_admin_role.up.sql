@@ -0,0 +1,32 @@
+-- the enforcer's adapter creates casbin_rule when it starts, it's created
+-- here the same way so the admin role can be seeded before it has.
+CREATE TABLE IF NOT EXISTS casbin_rule (
+  p_type VARCHAR(32)  DEFAULT '' NOT NULL,
+  v0     VARCHAR(255) DEFAULT '' NOT NULL,
+  v1     VARCHAR(255) DEFAULT '' NOT NULL,
+  v2     VARCHAR(255) DEFAULT '' NOT NULL,
+  v3     VARCHAR(255) DEFAULT '' NOT NULL,
+  v4     VARCHAR(255) DEFAULT '' NOT NULL,
+  v5     VARCHAR(255) DEFAULT '' NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_casbin_rule ON casbin_rule (p_type, v0, v1);
+
+INSERT INTO casbin_rule (p_type, v0, v1, v2, v3, v4, v5)
+SELECT 'g', 'Gardego5', 'role:admin', '', '', '', ''
+WHERE NOT EXISTS (
+  SELECT 1 FROM casbin_rule WHERE p_type = 'g' AND v0 = 'Gardego5' AND v1 = 'role:admin'
+);
+
+INSERT INTO casbin_rule (p_type, v0, v1, v2, v3, v4, v5)
+SELECT 'p', rule.sub_rule, rule.obj, rule.act, '', '', ''
+FROM (
+            SELECT 'hasRole(r.sub, "admin")' AS sub_rule, '/admin/*' AS obj, 'GET' AS act
+  UNION ALL SELECT 'hasRole(r.sub, "admin")', '/admin/*', 'POST'
+  UNION ALL SELECT 'hasRole(r.sub, "admin")', '/admin/*', 'PUT'
+  UNION ALL SELECT 'hasRole(r.sub, "admin")', '/admin/*', 'DELETE'
+) AS rule
+WHERE NOT EXISTS (
+  SELECT 1 FROM casbin_rule
+  WHERE p_type = 'p' AND v0 = rule.sub_rule AND v1 = rule.obj AND v2 = rule.act
+);
@@ -0,0 +1,15 @@
+-- times were stored as RFC3339, in whatever zone they were made in and
+-- without trailing zeros, so they didn't compare as text in time order.
+-- They're rewritten in UTC with every fractional digit, like model.Time
+-- stores them now. sqlite keeps milliseconds, so finer digits are zeroed.
+
+UPDATE contact_messages SET created_at = strftime('%Y-%m-%dT%H:%M:%f', created_at) || '000000Z' WHERE created_at IS NOT NULL;
+UPDATE policy_changes SET created_at = strftime('%Y-%m-%dT%H:%M:%f', created_at) || '000000Z' WHERE created_at IS NOT NULL;
+
+-- the audit log is append only, except for this once.
+DROP TRIGGER audit_events_no_update;
+UPDATE audit_events SET created_at = strftime('%Y-%m-%dT%H:%M:%f', created_at) || '000000Z' WHERE created_at IS NOT NULL;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append only');
+END;
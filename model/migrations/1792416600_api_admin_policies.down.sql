@@ -0,0 +1,3 @@
+DELETE FROM casbin_rule
+WHERE p_type = 'p' AND v0 = 'hasRole(r.sub, "admin")' AND v1 = '/api/v1/*'
+  AND v2 IN ('GET', 'DELETE');
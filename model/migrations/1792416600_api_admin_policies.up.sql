@@ -0,0 +1,12 @@
+-- admins can use the api, ie. to export messages with a token. Sending a
+-- message is public, so it isn't included.
+INSERT INTO casbin_rule (p_type, v0, v1, v2, v3, v4, v5)
+SELECT 'p', rule.sub_rule, rule.obj, rule.act, '', '', ''
+FROM (
+            SELECT 'hasRole(r.sub, "admin")' AS sub_rule, '/api/v1/*' AS obj, 'GET' AS act
+  UNION ALL SELECT 'hasRole(r.sub, "admin")', '/api/v1/*', 'DELETE'
+) AS rule
+WHERE NOT EXISTS (
+  SELECT 1 FROM casbin_rule
+  WHERE p_type = 'p' AND v0 = rule.sub_rule AND v1 = rule.obj AND v2 = rule.act
+);
@@ -0,0 +1,6 @@
+DELETE FROM casbin_rule
+WHERE p_type = 'p' AND v0 = 'hasRole(r.sub, "admin")' AND v1 = '/metrics' AND v2 = 'GET';
+
+DELETE FROM casbin_rule
+WHERE p_type = 'p' AND v0 = 'hasRole(r.sub, "metrics")'
+  AND v1 IN ('/metrics', '/admin/tokens', '/admin/tokens/*');
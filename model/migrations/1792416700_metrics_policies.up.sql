@@ -0,0 +1,16 @@
+-- metrics are scraped with an api token, made by an admin, or by an account
+-- given the metrics role, which can only see metrics and manage its own
+-- tokens.
+INSERT INTO casbin_rule (p_type, v0, v1, v2, v3, v4, v5)
+SELECT 'p', rule.sub_rule, rule.obj, rule.act, '', '', ''
+FROM (
+            SELECT 'hasRole(r.sub, "admin")' AS sub_rule, '/metrics' AS obj, 'GET' AS act
+  UNION ALL SELECT 'hasRole(r.sub, "metrics")', '/metrics', 'GET'
+  UNION ALL SELECT 'hasRole(r.sub, "metrics")', '/admin/tokens', 'GET'
+  UNION ALL SELECT 'hasRole(r.sub, "metrics")', '/admin/tokens', 'POST'
+  UNION ALL SELECT 'hasRole(r.sub, "metrics")', '/admin/tokens/*', 'DELETE'
+) AS rule
+WHERE NOT EXISTS (
+  SELECT 1 FROM casbin_rule
+  WHERE p_type = 'p' AND v0 = rule.sub_rule AND v1 = rule.obj AND v2 = rule.act
+);
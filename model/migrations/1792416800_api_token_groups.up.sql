@@ -0,0 +1,3 @@
+-- tokens keep the groups of their owner, since they can't be looked up again
+-- without the owner's oauth token.
+ALTER TABLE api_tokens ADD COLUMN groups TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- the rewritten times are still RFC3339, which is all that was expected of
+-- them before, so there's nothing to undo.
@@ -0,0 +1,7 @@
+-- api token times are rewritten in UTC with every fractional digit, like
+-- the other tables were in fixed_width_times.
+
+UPDATE api_tokens SET expires_at = strftime('%Y-%m-%dT%H:%M:%f', expires_at) || '000000Z' WHERE expires_at IS NOT NULL;
+UPDATE api_tokens SET last_used_at = strftime('%Y-%m-%dT%H:%M:%f', last_used_at) || '000000Z' WHERE last_used_at IS NOT NULL;
+UPDATE api_tokens SET revoked_at = strftime('%Y-%m-%dT%H:%M:%f', revoked_at) || '000000Z' WHERE revoked_at IS NOT NULL;
+UPDATE api_tokens SET created_at = strftime('%Y-%m-%dT%H:%M:%f', created_at) || '000000Z' WHERE created_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE policy_changes DROP COLUMN role;
@@ -0,0 +1,2 @@
+-- roles being assigned and unassigned are recorded with the policy changes.
+ALTER TABLE policy_changes ADD COLUMN role TEXT;
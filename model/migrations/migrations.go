@@ -0,0 +1,8 @@
+// Package migrations has the migrations of the database, in the order
+// they're applied by their names.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
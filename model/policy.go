@@ -0,0 +1,115 @@
+//go:generate msgp
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Policy is a casbin p rule. A request is allowed by it when
+//
+//	eval(SubRule) && keyMatch4(r.obj, Obj) && r.act == Act
+type Policy struct {
+	SubRule string `json:"sub_rule" validate:"required"`
+	Obj     string `json:"obj" validate:"required"`
+	Act     string `json:"act" validate:"required"`
+}
+
+func PolicyFromRule(rule []string) Policy {
+	rule = append(rule, "", "", "")
+	return Policy{SubRule: rule[0], Obj: rule[1], Act: rule[2]}
+}
+
+func (p Policy) Rule() []string { return []string{p.SubRule, p.Obj, p.Act} }
+
+// NullPolicy stores a policy in a single column, which is NULL when the
+// policy isn't valid.
+type NullPolicy struct {
+	Policy
+	Valid bool
+}
+
+func (p *NullPolicy) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*p = NullPolicy{}
+		return nil
+	case string:
+		p.Valid = true
+		return json.Unmarshal([]byte(value), &p.Policy)
+	default:
+		return fmt.Errorf("expected string as internal db value, got %T", value)
+	}
+}
+
+func (p NullPolicy) Value() (driver.Value, error) {
+	if !p.Valid {
+		return nil, nil
+	} else if b, err := json.Marshal(p.Policy); err != nil {
+		return nil, err
+	} else {
+		return string(b), nil
+	}
+}
+
+// RoleAssignment is a casbin g rule, giving Role to Subject, which is a user
+// like "Gardego5", or a github group like "@org" or "@org/team". Role is
+// named without the prefix roles have in g rules.
+type RoleAssignment struct {
+	Subject string `json:"subject" validate:"required"`
+	Role    string `json:"role" validate:"required"`
+}
+
+// NullRoleAssignment stores a role assignment in a single column, which is
+// NULL when it isn't valid.
+type NullRoleAssignment struct {
+	RoleAssignment
+	Valid bool
+}
+
+func (a *NullRoleAssignment) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*a = NullRoleAssignment{}
+		return nil
+	case string:
+		a.Valid = true
+		return json.Unmarshal([]byte(value), &a.RoleAssignment)
+	default:
+		return fmt.Errorf("expected string as internal db value, got %T", value)
+	}
+}
+
+func (a NullRoleAssignment) Value() (driver.Value, error) {
+	if !a.Valid {
+		return nil, nil
+	} else if b, err := json.Marshal(a.RoleAssignment); err != nil {
+		return nil, err
+	} else {
+		return string(b), nil
+	}
+}
+
+type PolicyAction string
+
+const (
+	PolicyActionAdd    PolicyAction = "add"
+	PolicyActionUpdate PolicyAction = "update"
+	PolicyActionRemove PolicyAction = "remove"
+
+	PolicyActionAssign   PolicyAction = "assign"
+	PolicyActionUnassign PolicyAction = "unassign"
+)
+
+// PolicyChange records who changed a policy, and how. Role is the role
+// assignment that was assigned or unassigned, rather than a policy.
+type PolicyChange struct {
+	ID        int                `db:"id"`
+	Actor     string             `db:"actor"`
+	Action    PolicyAction       `db:"action"`
+	OldRule   NullPolicy         `db:"old_rule"`
+	NewRule   NullPolicy         `db:"new_rule"`
+	Role      NullRoleAssignment `db:"role"`
+	CreatedAt Time               `db:"created_at"`
+}
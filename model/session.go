@@ -0,0 +1,15 @@
+//go:generate msgp
+package model
+
+import "time"
+
+type Session struct {
+	ID        string    `msg:"id"`
+	User      string    `msg:"user"`
+	CreatedAt time.Time `msg:"created_at"`
+	LastSeen  time.Time `msg:"last_seen"`
+	IP        string    `msg:"ip"`
+	UserAgent string    `msg:"user_agent"`
+	Flash     []string  `msg:"flash"`
+	CSRFToken string    `msg:"csrf_token"`
+}
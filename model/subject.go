@@ -2,5 +2,13 @@
 package model
 
 type Subject struct {
+	// Provider is the name of the oauth provider the subject signed in with.
+	Provider string `msg:"provider"`
+	// User identifies the subject in policies. It must be unique across
+	// providers, so github logins are used as is, and users of other
+	// providers are prefixed with the provider's name, ie. "gitlab/someone".
 	User string `msg:"user"`
+	// Groups are the github organizations ("@org") and teams ("@org/team")
+	// the subject is a member of, which may be given roles like users can.
+	Groups []string `msg:"groups"`
 }
@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// TimeFormat is how times are stored. They're always UTC, and always have
+// every fractional digit, so comparing them as text orders them in time.
+const TimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
 type Time struct{ time.Time }
 
 func (t *Time) Scan(value any) error {
@@ -18,9 +22,30 @@ func (t *Time) Scan(value any) error {
 }
 
 func (t Time) Value() (driver.Value, error) {
-	if time, err := t.Time.MarshalText(); err != nil {
-		return nil, err
-	} else {
-		return string(time), nil
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return nil, fmt.Errorf("year %d is outside of [0,9999]", y)
+	}
+	return t.UTC().Format(TimeFormat), nil
+}
+
+// NullTime is a Time that may be NULL in the database.
+type NullTime struct {
+	Time
+	Valid bool
+}
+
+func (t *NullTime) Scan(value any) error {
+	if value == nil {
+		*t = NullTime{}
+		return nil
+	}
+	t.Valid = true
+	return t.Time.Scan(value)
+}
+
+func (t NullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
 	}
+	return t.Time.Value()
 }
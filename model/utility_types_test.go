@@ -0,0 +1,42 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/model"
+)
+
+func TestTimeValue(t *testing.T) {
+	base := time.Date(2026, 10, 19, 13, 10, 20, 0, time.UTC)
+	// ordered in time, but not as RFC3339Nano text, which drops trailing
+	// zeros and keeps the zone.
+	times := []time.Time{
+		base,
+		base.Add(time.Nanosecond),
+		base.Add(100 * time.Millisecond),
+		base.Add(time.Second).In(time.FixedZone("", -7*60*60)),
+	}
+
+	var previous string
+	for _, tm := range times {
+		value, err := Time{Time: tm}.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := value.(string)
+		if len(text) != len(TimeFormat)-len("Z07:00")+len("Z") {
+			t.Errorf("%s: expected a fixed width, got %q", tm, text)
+		} else if text <= previous {
+			t.Errorf("expected %q to sort after %q", text, previous)
+		}
+		previous = text
+
+		var scanned Time
+		if err = scanned.Scan(text); err != nil {
+			t.Fatal(err)
+		} else if !scanned.Equal(tm) {
+			t.Errorf("expected %s to be scanned back, got %s", tm, scanned)
+		}
+	}
+}
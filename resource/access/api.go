@@ -0,0 +1,14 @@
+package access
+
+import (
+	"context"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+)
+
+// API reports whether the request is to the json api, which is sent errors
+// as json rather than pages.
+func API(c context.Context) bool {
+	api, _ := c.Value(internal.API).(bool)
+	return api
+}
@@ -0,0 +1,32 @@
+package access
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+)
+
+// RequestId identifies the request in logs and audit events.
+func RequestId(c context.Context) string {
+	id, _ := c.Value(internal.RequestId).(string)
+	return id
+}
+
+// AuditEvent describes action being done to target by the actor of the
+// request, from where the request came from.
+func AuditEvent(c context.Context, action model.AuditAction, target string, result model.AuditResult) model.AuditEvent {
+	event := model.AuditEvent{
+		Actor:     Actor(c),
+		Action:    action,
+		Target:    target,
+		RequestID: RequestId(c),
+		Result:    result,
+	}
+	if r, ok := c.Value(internal.RequestRef).(*http.Request); ok {
+		event.IP = utils.ClientIP(r)
+	}
+	return event
+}
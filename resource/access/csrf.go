@@ -0,0 +1,18 @@
+package access
+
+import (
+	"context"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+)
+
+// CSRFToken is the token state changing requests from this session must
+// send, starting a session if there isn't one yet. Only ask for it when
+// rendering something that changes state. It is empty if the CSRF middleware
+// isn't in use.
+func CSRFToken(c context.Context) string {
+	if token, ok := c.Value(internal.CSRFToken).(func() string); ok {
+		return token()
+	}
+	return ""
+}
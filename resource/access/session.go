@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/Gardego5/garrettdavis.dev/model"
 	"github.com/Gardego5/garrettdavis.dev/resource/internal"
 	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
 )
@@ -11,13 +12,14 @@ import (
 func Session(c context.Context) string {
 	logger := Logger(c, "Session")
 	w := c.Value(internal.WriterRef).(http.ResponseWriter)
+	sessionCookie := c.Value(internal.SessionCookie).(*cookie.Codec[cookie.SessionValue])
 
 	for _, line := range w.Header()["Set-Cookie"] {
 		c, err := http.ParseSetCookie(line)
-		if err != nil {
+		if err != nil || c.Name != sessionCookie.Name() {
 			continue
-		} else if c.Name == cookie.Session.Name {
-			return c.Value
+		} else if value, err := sessionCookie.Decode(c.Value); err == nil {
+			return value.ID
 		}
 	}
 
@@ -28,3 +30,19 @@ func Session(c context.Context) string {
 
 	return session
 }
+
+// SessionData is the server side session of the request, or nil if it
+// doesn't have one yet.
+func SessionData(c context.Context) *model.Session {
+	session, _ := c.Value(internal.SessionData).(*model.Session)
+	return session
+}
+
+// Flashes removes and returns the messages queued for the session to see on
+// the next page it renders.
+func Flashes(c context.Context) []string {
+	if flashes, ok := c.Value(internal.Flashes).(func() []string); ok {
+		return flashes()
+	}
+	return nil
+}
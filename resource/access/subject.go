@@ -0,0 +1,33 @@
+package access
+
+import (
+	"context"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+)
+
+// Subject is who the Authorization middleware allowed the request as, or nil
+// outside of it.
+func Subject(c context.Context) *model.Subject {
+	sub, _ := c.Value(internal.Subject).(*model.Subject)
+	return sub
+}
+
+// APIToken is the token the request was authorized with, or nil if it was
+// authorized by its session.
+func APIToken(c context.Context) *model.APIToken {
+	token, _ := c.Value(internal.APIToken).(*model.APIToken)
+	return token
+}
+
+// Actor is the user doing whatever the request does, which is empty for
+// anonymous users.
+func Actor(c context.Context) string {
+	if sub := Subject(c); sub != nil {
+		return sub.User
+	} else if data := SessionData(c); data != nil {
+		return data.User
+	}
+	return ""
+}
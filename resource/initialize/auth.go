@@ -0,0 +1,92 @@
+package initialize
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/service/auth"
+	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/goutils/env"
+)
+
+// FakeIssuerPath is where the fake provider's issuer is served.
+const FakeIssuerPath = "/auth/fake-issuer"
+
+type AuthConfig struct {
+	// Providers are the names of the providers to sign in with, in the order
+	// they are offered. Each one loads the rest of its configuration from
+	// the environment, besides github which is always configured.
+	Providers          []string
+	BaseUrl            string
+	GithubClientId     string
+	GithubClientSecret string
+	Users              *currentuser.Service
+}
+
+// Auth creates the auth service. The fake provider also needs FakeIssuer to
+// be served.
+func Auth(cfg AuthConfig) (*auth.Service, error) {
+	var providers []auth.Provider
+	for _, name := range cfg.Providers {
+		switch name {
+		case model.ProviderGithub:
+			providers = append(providers, auth.NewGithub(cfg.GithubClientId, cfg.GithubClientSecret, cfg.Users))
+
+		case model.ProviderGitlab:
+			e, err := env.Load[struct {
+				Url          string `env:"GITLAB_URL=https://gitlab.com"`
+				ClientId     string `env:"GITLAB_OAUTH_CLIENT_ID"`
+				ClientSecret string `env:"GITLAB_OAUTH_CLIENT_SECRET"`
+			}]()
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, auth.NewGitlab(e.Url, e.ClientId, e.ClientSecret))
+
+		case "oidc":
+			e, err := env.Load[struct {
+				Name         string `env:"OIDC_NAME=oidc"`
+				Title        string `env:"OIDC_TITLE=Single Sign-On"`
+				Issuer       string `env:"OIDC_ISSUER"`
+				ClientId     string `env:"OIDC_CLIENT_ID"`
+				ClientSecret string `env:"OIDC_CLIENT_SECRET"`
+				UserClaim    string `env:"OIDC_USER_CLAIM=sub"`
+			}]()
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, auth.NewOIDC(auth.OIDCConfig(*e)))
+
+		case "fake":
+			providers = append(providers, auth.NewOIDC(auth.OIDCConfig{
+				Name:      "fake",
+				Title:     "the fake provider",
+				Issuer:    strings.TrimSuffix(cfg.BaseUrl, "/") + FakeIssuerPath,
+				ClientId:  "fake",
+				UserClaim: "sub",
+			}))
+
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", name)
+		}
+	}
+
+	return auth.New(cfg.BaseUrl, providers...), nil
+}
+
+// FakeIssuer is the issuer of the fake provider, to be served at
+// FakeIssuerPath, or nil when the fake provider isn't enabled. It is served
+// outside of the application's middleware, like any other provider would be.
+func FakeIssuer(providers []string, baseUrl string) (http.Handler, error) {
+	if !slices.Contains(providers, "fake") {
+		return nil, nil
+	}
+	fake, err := auth.NewFake(strings.TrimSuffix(baseUrl, "/") + FakeIssuerPath)
+	if err != nil {
+		return nil, err
+	}
+	return http.StripPrefix(FakeIssuerPath, fake), nil
+}
@@ -1,17 +1,73 @@
 package initialize
 
 import (
+	"crypto/cipher"
+	"time"
+
 	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
 	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
 	"github.com/google/go-github/v66/github"
 	"github.com/redis/go-redis/v9"
 )
 
+type (
+	CacheBackend string
+	CacheConfig  struct {
+		// Backend selects where cached values are stored.
+		Backend CacheBackend
+		// Size is the maximum number of entries held by the memory backend.
+		Size int
+		// L1Size enables a local cache of up to L1Size entries in front of
+		// the redis backend when greater than zero.
+		L1Size int
+		// AEAD encrypts entries holding credentials.
+		AEAD cipher.AEAD
+		// Metrics counts the lookups of every cache.
+		Metrics *metrics.Service
+	}
+)
+
+const (
+	CacheBackendRedis  CacheBackend = "redis"
+	CacheBackendMemory CacheBackend = "memory"
+
+	// how long an entry may be served from the local cache before going back
+	// to redis. writes are invalidated immediately, this only bounds drift.
+	l1TTL = 30 * time.Second
+)
+
 // registry for all the caches to add to the context
-func Caches(rdb *redis.Client) bimarshal.RegisteredCaches {
+//
+// the backend selects where cached values are stored. the memory backend keeps
+// up to Size entries in process, and is useful for local development & tests
+// where there is no redis available. otherwise values are kept in redis, with
+// a local cache in front of it when L1Size is set. close stops the local
+// cache listening for invalidations.
+func Caches(rdb *redis.Client, cfg CacheConfig) (_ bimarshal.RegisteredCaches, close func() error) {
+	var store bimarshal.Store
+	close = func() error { return nil }
+	switch {
+	case cfg.Backend == CacheBackendMemory:
+		store = bimarshal.NewMemoryStore(cfg.Size)
+	case cfg.L1Size > 0:
+		tiered := bimarshal.NewTieredStore(rdb, cfg.L1Size, l1TTL)
+		store, close = tiered, tiered.Close
+	default:
+		store = bimarshal.NewRedisStore(rdb)
+	}
+
 	return bimarshal.Caches{
-		"user":         bimarshal.Register[github.User](bimarshal.JSON),
-		"access-token": bimarshal.Register[model.GHAccessToken](bimarshal.MessagePack),
-		"subject":      bimarshal.Register[model.Subject](bimarshal.MessagePack),
-	}.Build(rdb)
+		"user": bimarshal.Register[github.User](bimarshal.JSON,
+			bimarshal.StaleWhileRevalidate(10*time.Minute),
+			bimarshal.NegativeTTL(30*time.Second),
+			bimarshal.Compress()),
+		"github-groups": bimarshal.Register[model.GithubGroups](bimarshal.MessagePack,
+			bimarshal.StaleWhileRevalidate(10*time.Minute)),
+		"access-token": bimarshal.Register[model.AccessToken](bimarshal.MessagePack,
+			bimarshal.Encrypt(cfg.AEAD)),
+		"session": bimarshal.Register[model.Session](bimarshal.MessagePack,
+			bimarshal.Encrypt(cfg.AEAD)),
+		"subject": bimarshal.Register[model.Subject](bimarshal.MessagePack),
+	}.Build(store, bimarshal.Observe(cfg.Metrics.CacheLookup)), close
 }
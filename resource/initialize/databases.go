@@ -24,7 +24,18 @@ func NewDB(databaseUrl, authToken string) *sqlx.DB {
 	return sqlx.NewDb(db, driverName)
 }
 
-func NewRedis(url string) *redis.Client {
+// NewRedis connects to redis at url, which is only required by the redis
+// cache backend. Without it there's no client, and everything that would use
+// redis keeps to this machine.
+func NewRedis(url string, backend CacheBackend) *redis.Client {
+	if url == "" {
+		if backend == CacheBackendRedis {
+			slog.Error("REDIS_URL is required by the redis cache backend")
+			os.Exit(1)
+		}
+		return nil
+	}
+
 	opts, err := redis.ParseURL(url)
 	if err != nil {
 		slog.Error("Error parsing redis URL", "error", err)
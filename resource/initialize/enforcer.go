@@ -5,45 +5,86 @@ import (
 	"log/slog"
 
 	sqlxadapter "github.com/Blank-Xu/sqlx-adapter"
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
+	"github.com/Gardego5/garrettdavis.dev/service/policies"
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/jmoiron/sqlx"
 )
 
 //go:embed enforcer.model.conf
 var ModelFile string
 
-func Enforcer(db *sqlx.DB, slogLogger *slog.Logger) (*casbin.Enforcer, error) {
-	adapter, err := sqlxadapter.NewAdapter(db, "")
+func Enforcer(db *sqlx.DB, slogLogger *slog.Logger, metrics *metrics.Service) (*casbin.SyncedEnforcer, error) {
+	sqlxAdapter, err := sqlxadapter.NewAdapter(db, "")
 	if err != nil {
 		return nil, err
 	}
+	adapter := &adapter{Adapter: sqlxAdapter, db: db}
 
 	model, err := model.NewModelFromString(ModelFile)
 	if err != nil {
 		return nil, err
 	}
 
-	enforcer, err := casbin.NewEnforcer(model, adapter)
+	enforcer, err := casbin.NewSyncedEnforcer(model, adapter)
 	if err != nil {
 		return nil, err
 	}
 
+	enforcer.AddFunction("hasRole", policies.HasRole(enforcer))
+
 	enforcer.EnableLog(true)
 
-	enforcer.SetLogger(&logger{logger: slogLogger})
+	// every decision is passed to the logger, which makes it the one place to
+	// count them.
+	enforcer.SetLogger(&logger{logger: slogLogger, metrics: metrics})
 
 	return enforcer, nil
 }
 
+// adapter loads rules with their values as they're stored. sqlxadapter joins
+// them with commas, and parses that as csv, which drops subject rules with
+// quotes in them, and splits the ones with commas.
+type adapter struct {
+	*sqlxadapter.Adapter
+	db *sqlx.DB
+}
+
+func (a *adapter) LoadPolicy(m model.Model) error {
+	rows, err := a.db.Query(`SELECT p_type, v0, v1, v2, v3, v4, v5 FROM casbin_rule`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := make([]string, 7)
+		if err := rows.Scan(&rule[0], &rule[1], &rule[2], &rule[3], &rule[4], &rule[5], &rule[6]); err != nil {
+			return err
+		}
+		// unused values are stored empty.
+		for len(rule) > 1 && rule[len(rule)-1] == "" {
+			rule = rule[:len(rule)-1]
+		}
+		if err := persist.LoadPolicyArray(rule, m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 type logger struct {
 	enabled bool
 	logger  *slog.Logger
+	metrics *metrics.Service
 }
 
 func (l *logger) EnableLog(enable bool) { l.enabled = enable }
 func (l *logger) IsEnabled() bool       { return l.enabled }
 func (l *logger) LogEnforce(matcher string, request []interface{}, result bool, explains [][]string) {
+	l.metrics.Enforced(result)
 	l.logger.Info("Enforce", "matcher", matcher, "request", request, "result", result, "explains", explains)
 }
 func (l *logger) LogModel(model [][]string) {
@@ -0,0 +1,22 @@
+package initialize
+
+import (
+	"log/slog"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiter counts requests where the caches are kept. With redis, limits
+// are shared by every machine, but each machine keeps its own while redis is
+// unavailable, or when there's no redis at all.
+func RateLimiter(rdb *redis.Client, backend CacheBackend, logger *slog.Logger) ratelimit.Limiter {
+	if backend == CacheBackendMemory || rdb == nil {
+		return ratelimit.NewMemory()
+	}
+
+	logger = logger.With("scope", "ratelimit")
+	return ratelimit.Fallback(ratelimit.NewRedis(rdb), ratelimit.NewMemory(), func(err error) {
+		logger.Warn("Error counting request in redis, counting it in memory", "error", err)
+	})
+}
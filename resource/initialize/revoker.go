@@ -0,0 +1,12 @@
+package initialize
+
+import "github.com/Gardego5/garrettdavis.dev/service/currentuser"
+
+// Revoker revokes access tokens with github on sign out, unless revoke is
+// false, in which case revocations are only recorded locally.
+func Revoker(revoke bool, clientId, clientSecret string) currentuser.TokenRevoker {
+	if !revoke {
+		return &currentuser.LocalRevoker{}
+	}
+	return currentuser.NewGithubRevoker(clientId, clientSecret)
+}
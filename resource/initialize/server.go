@@ -0,0 +1,22 @@
+package initialize
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Server serves handler at addr. Its timeouts keep slow clients from holding
+// connections open forever, they're generous enough for anything this site
+// sends.
+func Server(addr string, handler http.Handler, logger *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ErrorLog:          slog.NewLogLogger(logger.With("scope", "http.Server").Handler(), slog.LevelWarn),
+	}
+}
@@ -0,0 +1,65 @@
+package initialize
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// TracesExporter is where spans are sent, named like the values of
+// OTEL_TRACES_EXPORTER.
+type TracesExporter string
+
+const (
+	// TracesExporterOTLP sends spans to a collector, configured by the
+	// standard OTEL_EXPORTER_OTLP_* variables.
+	TracesExporterOTLP TracesExporter = "otlp"
+	// TracesExporterConsole writes spans to stderr, for local development.
+	TracesExporterConsole TracesExporter = "console"
+	// TracesExporterNone drops spans. Requests still get a trace id, which
+	// their logs are correlated with.
+	TracesExporterNone TracesExporter = "none"
+)
+
+// Tracing sets up the global tracer provider, and the W3C trace context
+// propagator. It has to be shut down to send the last spans.
+func Tracing(ctx context.Context, exporter TracesExporter, version string) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("garrettdavis.dev"), semconv.ServiceVersion(version)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	switch exporter {
+	case TracesExporterOTLP:
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case TracesExporterConsole:
+		// logs are written to stdout, so spans aren't mixed in with them.
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithSyncer(exp))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return provider, nil
+}
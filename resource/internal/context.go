@@ -4,14 +4,22 @@ type Key int
 
 const (
 	_ Key = iota
+	API
+	APIToken
+	AccessLog
+	CSRFToken
 	Enforcer
 	Fileserver
+	Flashes
 	Logger
 	RequestId
 	RequestRef
 	RouterMethod
 	RouterPath
 	Session
+	SessionCookie
+	SessionData
+	Subject
 	Validate
 	WriterRef
 )
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+)
+
+// API marks requests as being to the json api, so that errors, including
+// ones from other middleware, are sent as json. Clients that won't accept
+// json are refused.
+var API mux.Middleware = mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		if !utils.Accepts(r.Header.Get("Accept"), "application/json") {
+			render.Error(w, render.ErrorDetail{
+				Status:  http.StatusNotAcceptable,
+				Message: "The api only responds with application/json.",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), internal.API, true)))
+	})
+})
+
+// wantsJSON is whether r is from an api client, for middleware that run
+// before API, which recognize them by only wanting json.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return accept != "" && utils.Accepts(accept, "application/json") && !utils.Accepts(accept, "text/html")
+}
@@ -1,52 +1,190 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/Gardego5/garrettdavis.dev/components"
 	"github.com/Gardego5/garrettdavis.dev/model"
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
 	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/service/tokens"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
 	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	html "github.com/Gardego5/htmdsl"
 	"github.com/casbin/casbin/v2"
-	"github.com/redis/go-redis/v9"
 )
 
+// Authorization only lets subjects the enforcer allows through. The github
+// groups of subjects are looked up again with users, rather than trusting
+// the ones from when they signed in, so roles given to groups follow
+// changes to their members. Subjects that aren't allowed are audited.
+//
+// Requests with an api token, in the Authorization header, act as the
+// subject that created the token, with the groups it had then, instead of
+// the session, limited to the scopes of the token.
 func Authorization(
 	logger *slog.Logger,
-	enforcer *casbin.Enforcer,
-	currentuser *currentuser.Service,
+	enforcer *casbin.SyncedEnforcer,
+	sessions *session.Service,
+	users *currentuser.Service,
+	apiTokens *tokens.Service,
+	audits *audit.Service,
+	baseUrl string,
 ) mux.Middleware {
 	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
 		logger := logger.With("scope", "middleware.Authorization")
+
+		denied := func(ctx context.Context, r *http.Request, sub *model.Subject) {
+			logger.WarnContext(ctx, "unauthorized access", "subject", sub)
+			event := access.AuditEvent(ctx, model.AuditAccess, r.Method+" "+r.URL.Path, model.AuditDenied)
+			event.Actor = sub.User
+			if err := audits.Record(ctx, event); err != nil {
+				logger.ErrorContext(ctx, "error recording audit event", "error", err)
+			}
+		}
+
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
-			session := access.Session(ctx)
-			user, err := currentuser.GetUserBySession(ctx, session)
-			if err == redis.Nil {
+
+			if secret, ok := bearerToken(r); ok {
+				token, err := apiTokens.Authenticate(ctx, secret)
+				if errors.Is(err, tokens.ErrInvalid) || errors.Is(err, tokens.ErrExpired) {
+					logger.WarnContext(ctx, "rejected api token", "error", err)
+					tokenRejected(w, http.StatusUnauthorized, "invalid_token", "The token is invalid, revoked or expired.")
+					return
+				} else if err != nil {
+					render.ServeError(w, r, mux.NewError(http.StatusInternalServerError,
+						"The token couldn't be checked.", fmt.Errorf("authenticating api token: %w", err)))
+					return
+				}
+
+				sub := token.Subject()
+				logUser(ctx, sub.User)
+				if !token.Scopes.Allows(r.Method) {
+					denied(ctx, r, &sub)
+					tokenRejected(w, http.StatusForbidden, "insufficient_scope", "The token's scopes don't allow "+r.Method+" requests.")
+					return
+				}
+
+				if ok, err := enforcer.Enforce(sub, r.URL.Path, r.Method); err != nil {
+					render.ServeError(w, r, mux.NewError(http.StatusInternalServerError,
+						"The policies couldn't be checked.", fmt.Errorf("enforcing policy: %w", err)))
+					return
+				} else if !ok {
+					denied(ctx, r, &sub)
+					tokenRejected(w, http.StatusForbidden, "insufficient_scope", sub.User+" isn't allowed to "+r.Method+" "+r.URL.Path+".")
+					return
+				}
+
+				logger.DebugContext(ctx, "authorized api token", "token", token.ID, "subject", sub)
+				ctx = context.WithValue(ctx, internal.Subject, &sub)
+				ctx = context.WithValue(ctx, internal.APIToken, token)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			sub, err := sessions.Subject(ctx, access.Session(ctx))
+			if errors.Is(err, bimarshal.ErrNotFound) {
 				logger.WarnContext(ctx, "session not found... the user probably hasn't signed in.")
-				w.WriteHeader(http.StatusUnauthorized)
+				signInRequired(w, r, http.StatusUnauthorized, baseUrl,
+					"You need to sign in to see this page.", "Sign in")
 				return
 			} else if err != nil {
-				logger.ErrorContext(ctx, "error getting user by session", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
+				render.ServeError(w, r, mux.NewError(http.StatusInternalServerError,
+					"Your session couldn't be checked.", fmt.Errorf("getting subject by session: %w", err)))
 				return
 			}
 
-			sub := model.Subject{User: user.GetLogin()}
+			logUser(ctx, sub.User)
+			if groups, err := users.GetGroupsBySession(ctx, access.Session(ctx)); err == nil {
+				sub.Groups = groups
+			} else if !errors.Is(err, currentuser.ErrNotGithub) {
+				logger.WarnContext(ctx, "error getting groups, using the ones from signing in", "error", err)
+			}
 
-			if ok, err := enforcer.Enforce(sub, r.URL.Path, r.Method); err != nil {
-				logger.ErrorContext(ctx, "error enforcing policy", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
+			if ok, err := enforcer.Enforce(*sub, r.URL.Path, r.Method); err != nil {
+				render.ServeError(w, r, mux.NewError(http.StatusInternalServerError,
+					"The policies couldn't be checked.", fmt.Errorf("enforcing policy: %w", err)))
 				return
 			} else if !ok {
-				logger.WarnContext(ctx, "unauthorized access", "subject", sub)
-				w.WriteHeader(http.StatusForbidden)
+				denied(ctx, r, sub)
+				signInRequired(w, r, http.StatusForbidden, baseUrl,
+					"You're signed in as "+sub.User+", who isn't allowed to see this page.", "Sign in as someone else")
 				return
 			}
 
-			next.ServeHTTP(w, r)
-			return
+			ctx = context.WithValue(ctx, internal.Subject, sub)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
 }
+
+// bearerToken is the token of a request with an Authorization header like
+// "Bearer <token>".
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// tokenRejected tells a client authenticating with an api token why it was
+// rejected. It's a script, not a person, so there's no page to sign in.
+func tokenRejected(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, code, description))
+	render.Error(w, render.ErrorDetail{Status: status, Code: code, Message: description})
+}
+
+// signInRequired prompts the user to sign in, and come back to the page they
+// were trying to see. htmx requests are for part of a page, which can't show
+// the prompt, so the whole page is sent to the sign in page instead. The api
+// is just told why.
+func signInRequired(w http.ResponseWriter, r *http.Request, status int, baseUrl, message, action string) {
+	if access.API(r.Context()) {
+		if status == http.StatusUnauthorized {
+			message = "This needs an api token, sent as a bearer token."
+		}
+		render.Error(w, render.ErrorDetail{Status: status, Message: message})
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		next, _ := utils.LocalURL(r.Header.Get("HX-Current-URL"), baseUrl)
+		w.Header().Set("HX-Redirect", signInURL(next))
+		w.WriteHeader(status)
+		return
+	}
+
+	next := ""
+	if r.Method == http.MethodGet {
+		next = r.URL.RequestURI()
+	}
+
+	w.WriteHeader(status)
+	render.Page(w, r, nil,
+		components.Header{Title: "Sign in"},
+		components.Margins{
+			html.P{message},
+			html.A{html.Class("underline"), html.Attrs{"href": signInURL(next)}, action},
+		})
+}
+
+func signInURL(next string) string {
+	if next == "" {
+		return "/auth/signin"
+	}
+	return "/auth/signin?" + url.Values{"next": {next}}.Encode()
+}
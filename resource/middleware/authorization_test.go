@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/initialize"
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/service/policies"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/service/tokens"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/testdb"
+	"github.com/casbin/casbin/v2"
+	casbinmodel "github.com/casbin/casbin/v2/model"
+	"github.com/google/go-github/v66/github"
+)
+
+func TestAuthorizationTokens(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	apiTokens, err := tokens.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audits, err := audit.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := casbinmodel.NewModelFromString(initialize.ModelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction("hasRole", policies.HasRole(enforcer))
+	enforcer.AddPolicies([][]string{
+		{`hasRole(r.sub, "admin")`, "/admin/*", "GET"},
+		{`hasRole(r.sub, "admin")`, "/admin/*", "POST"},
+	})
+	enforcer.AddGroupingPolicy("Gardego5", policies.RolePrefix+"admin")
+
+	caches := bimarshal.Caches{
+		"user":          bimarshal.Register[github.User](bimarshal.JSON),
+		"github-groups": bimarshal.Register[model.GithubGroups](bimarshal.MessagePack),
+		"access-token":  bimarshal.Register[model.AccessToken](bimarshal.MessagePack),
+		"session":       bimarshal.Register[model.Session](bimarshal.MessagePack),
+		"subject":       bimarshal.Register[model.Subject](bimarshal.MessagePack),
+	}.Build(bimarshal.NewMemoryStore(100))
+
+	server, _ := serve(t, 0, "/", func(w http.ResponseWriter, r *http.Request) {
+		if token := access.APIToken(r.Context()); token != nil {
+			w.Write([]byte(access.Subject(r.Context()).User))
+		}
+	},
+		Authorization(slog.Default(), enforcer, session.New(caches, time.Hour), currentuser.New(caches),
+			apiTokens, audits, "https://garrettdavis.dev"),
+	)
+
+	admin := model.Subject{Provider: model.ProviderGithub, User: "Gardego5"}
+	read, _, _ := apiTokens.Create(ctx, admin, "read", model.Scopes{model.TokenScopeRead}, time.Time{})
+	write, _, _ := apiTokens.Create(ctx, admin, "write", model.Scopes{model.TokenScopeWrite}, time.Time{})
+	expired, _, _ := apiTokens.Create(ctx, admin, "expired", model.Scopes{model.TokenScopeWrite}, time.Now().Add(-time.Minute))
+	revoked, token, _ := apiTokens.Create(ctx, admin, "revoked", model.Scopes{model.TokenScopeWrite}, time.Time{})
+	apiTokens.Revoke(ctx, admin.User, token.ID)
+	someone, _, _ := apiTokens.Create(ctx, model.Subject{Provider: model.ProviderGithub, User: "someone"},
+		"someone", model.Scopes{model.TokenScopeWrite}, time.Time{})
+
+	for _, test := range []struct {
+		name, method, token string
+		status              int
+		// error is the error of the WWW-Authenticate header, which is only
+		// sent when a token is rejected.
+		error string
+	}{
+		{"read", "GET", read, http.StatusOK, ""},
+		{"read can't write", "POST", read, http.StatusForbidden, "insufficient_scope"},
+		{"write", "POST", write, http.StatusOK, ""},
+		{"write can read", "GET", write, http.StatusOK, ""},
+		{"not allowed by policy", "GET", someone, http.StatusForbidden, "insufficient_scope"},
+		{"not a token", "GET", "not a token", http.StatusUnauthorized, "invalid_token"},
+		{"unknown", "GET", tokens.Prefix + "unknown", http.StatusUnauthorized, "invalid_token"},
+		{"expired", "GET", expired, http.StatusUnauthorized, "invalid_token"},
+		{"revoked", "GET", revoked, http.StatusUnauthorized, "invalid_token"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, server.URL+"/admin/messages", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, res.StatusCode)
+			}
+			header := res.Header.Get("WWW-Authenticate")
+			if test.error == "" && header != "" {
+				t.Errorf("expected no WWW-Authenticate header, got %q", header)
+			} else if test.error != "" && !strings.HasPrefix(header, `Bearer error="`+test.error+`", error_description=`) {
+				t.Errorf("expected a WWW-Authenticate header with error %q, got %q", test.error, header)
+			}
+		})
+	}
+
+	// rejected tokens aren't subjects, only subjects that aren't allowed are
+	// audited.
+	if n, err := audits.CountEvents(ctx, &audit.ListEventsInput{Result: model.AuditDenied}); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Errorf("expected 2 denied requests to be audited, got %d", n)
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+)
+
+// ClientIP makes the client address fly's proxy forwards in Fly-Client-IP
+// the RemoteAddr of requests, when behindFly. Otherwise the header is
+// ignored, since anyone can send it, and only the proxy replaces it.
+func ClientIP(behindFly bool) mux.Middleware {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		if !behindFly {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := r.Header.Get("Fly-Client-IP"); ip != "" {
+				_, port, _ := net.SplitHostPort(r.RemoteAddr)
+				r.RemoteAddr = net.JoinHostPort(ip, port)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
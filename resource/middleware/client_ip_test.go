@@ -0,0 +1,32 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+)
+
+func TestClientIP(t *testing.T) {
+	for _, tc := range []struct {
+		behindFly bool
+		expected  string
+	}{
+		{true, "203.0.113.7"},
+		{false, "192.0.2.1"},
+	} {
+		var ip string
+		h := ClientIP(tc.behindFly).Use(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip = utils.ClientIP(r)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Fly-Client-IP", "203.0.113.7")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if ip != tc.expected {
+			t.Errorf("behind fly %v: expected %s, got %s", tc.behindFly, tc.expected, ip)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	html "github.com/Gardego5/htmdsl"
+)
+
+// CSRF rejects state changing requests that don't carry the csrf token of
+// their session, in either the X-CSRF-Token header (htmx requests) or the
+// csrf_token form field (forms). As a second line of defense, requests
+// that the browser says came from another site are rejected outright.
+//
+// Requests with an api token aren't checked, they're authorized by the token
+// rather than the session. Neither are json requests that aren't from another
+// site, browsers only send those cross site when the site allows it. Tokens,
+// and the sessions holding them, are only created when something that needs
+// one asks for it with access.CSRFToken, like a components.Form. It must come
+// after Sessions.
+func CSRF(sessions *session.Service, baseUrl string) mux.Middleware {
+	allowed := ""
+	if u, err := url.Parse(baseUrl); err == nil {
+		allowed = u.Host
+	}
+
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logger(ctx).With("scope", "middleware.CSRF")
+
+			ctx = context.WithValue(ctx, internal.CSRFToken, sync.OnceValue(func() string {
+				token, err := sessions.CSRFToken(ctx, access.Session(ctx), r)
+				if err != nil {
+					logger.ErrorContext(ctx, "error getting csrf token", "error", err)
+				}
+				return token
+			}))
+			r = r.WithContext(ctx)
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// browsers don't send api tokens by themselves, like they do
+			// cookies, so requests with one can't be forged.
+			if _, ok := bearerToken(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+				logger.WarnContext(ctx, "rejected cross site request", "sec-fetch-site", site)
+				csrfFailed(w, r)
+				return
+			}
+
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || (u.Host != r.Host && u.Host != allowed) {
+					logger.WarnContext(ctx, "rejected cross origin request", "origin", origin)
+					csrfFailed(w, r)
+					return
+				}
+			}
+
+			// forms can't send json, and scripts on other sites can't send it
+			// without permission, which isn't given.
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// htmx requests from forms send both, either one is enough.
+			data := access.SessionData(ctx)
+			matches := func(token string) bool {
+				return subtle.ConstantTimeCompare([]byte(token), []byte(data.CSRFToken)) == 1
+			}
+			if data == nil || data.CSRFToken == "" ||
+				!(matches(r.Header.Get("X-CSRF-Token")) || matches(r.FormValue("csrf_token"))) {
+				logger.WarnContext(ctx, "rejected request with invalid csrf token")
+				csrfFailed(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+func csrfFailed(w http.ResponseWriter, r *http.Request) {
+	if wantsJSON(r) {
+		render.Error(w, render.ErrorDetail{
+			Status:  http.StatusForbidden,
+			Message: "The request needs an api token, or a csrf token from this site.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	if r.Header.Get("HX-Request") == "true" {
+		html.RenderContext(w, r.Context(), html.P{"Your session has expired, please reload the page and try again."})
+		return
+	}
+	render.Page(w, r, nil, html.P{"Your session has expired, please go back, reload the page and try again."})
+}
@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+	"github.com/google/go-github/v66/github"
+)
+
+func TestCSRF(t *testing.T) {
+	keys, err := symetric.NewKeyring("a very long application secret used for testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := session.New(bimarshal.Caches{
+		"user":          bimarshal.Register[github.User](bimarshal.JSON),
+		"github-groups": bimarshal.Register[model.GithubGroups](bimarshal.MessagePack),
+		"access-token":  bimarshal.Register[model.AccessToken](bimarshal.MessagePack),
+		"session":       bimarshal.Register[model.Session](bimarshal.MessagePack),
+		"subject":       bimarshal.Register[model.Subject](bimarshal.MessagePack),
+	}.Build(bimarshal.NewMemoryStore(100)), time.Hour)
+
+	server, _ := serveTLS(t, 0, "/{page}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("page") == "form" {
+			io.WriteString(w, access.CSRFToken(r.Context()))
+		}
+	},
+		Inject(Syringe(utils.Ptr(render.StaticPathPrefix("/static")))),
+		Sessions(sessions, cookie.NewSession(keys)),
+		CSRF(sessions, "https://garrettdavis.dev"),
+	)
+	// the session cookie is secure, so it's only sent back over https.
+	client := server.Client()
+	client.Jar, _ = cookiejar.New(nil)
+
+	do := func(method, path string, header map[string]string, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// viewing a page doesn't start a session, rendering a form does.
+	do("GET", "/page", nil, "").Body.Close()
+	if list, _ := sessions.List(context.Background()); len(list) != 0 {
+		t.Fatalf("expected no sessions to be started, got %d", len(list))
+	}
+	res := do("GET", "/form", nil, "")
+	token, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if list, _ := sessions.List(context.Background()); len(list) != 1 || len(token) == 0 {
+		t.Fatalf("expected a session with a token to be started, got %d sessions and %q", len(list), token)
+	}
+
+	form := "application/x-www-form-urlencoded"
+	for _, test := range []struct {
+		name   string
+		header map[string]string
+		body   string
+		status int
+	}{
+		{"no token", map[string]string{"Content-Type": form}, "", http.StatusForbidden},
+		{"wrong token", map[string]string{"X-CSRF-Token": "wrong"}, "", http.StatusForbidden},
+		{"header", map[string]string{"X-CSRF-Token": string(token)}, "", http.StatusOK},
+		{"form", map[string]string{"Content-Type": form}, "csrf_token=" + url.QueryEscape(string(token)), http.StatusOK},
+		// either one is enough, a form may be sent with an old header.
+		{"form and old header", map[string]string{"Content-Type": form, "X-CSRF-Token": "old"},
+			"csrf_token=" + url.QueryEscape(string(token)), http.StatusOK},
+		{"json", map[string]string{"Content-Type": "application/json"}, "{}", http.StatusOK},
+		{"cross site json", map[string]string{"Content-Type": "application/json", "Sec-Fetch-Site": "cross-site"}, "{}", http.StatusForbidden},
+		{"bearer", map[string]string{"Authorization": "Bearer gd_token"}, "", http.StatusOK},
+		{"same origin", map[string]string{"X-CSRF-Token": string(token), "Sec-Fetch-Site": "same-origin"}, "", http.StatusOK},
+		{"cross site", map[string]string{"X-CSRF-Token": string(token), "Sec-Fetch-Site": "cross-site"}, "", http.StatusForbidden},
+		{"same site", map[string]string{"X-CSRF-Token": string(token), "Sec-Fetch-Site": "same-site"}, "", http.StatusForbidden},
+		{"origin", map[string]string{"X-CSRF-Token": string(token), "Origin": "https://garrettdavis.dev"}, "", http.StatusOK},
+		{"cross origin", map[string]string{"X-CSRF-Token": string(token), "Origin": "https://example.com"}, "", http.StatusForbidden},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			res := do("POST", "/submit", test.header, test.body)
+			res.Body.Close()
+			if res.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, res.StatusCode)
+			}
+		})
+	}
+}
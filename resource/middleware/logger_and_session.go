@@ -1,78 +1,189 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/Gardego5/garrettdavis.dev/resource/internal"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils"
 	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
 	"github.com/Gardego5/garrettdavis.dev/utils/mux"
-	html "github.com/Gardego5/htmdsl"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func logger(ctx context.Context) *slog.Logger {
 	return ctx.Value(internal.Logger).(*slog.Logger)
 }
 
+// loggingWriter records the response, for the middleware that log and
+// measure it. It can be flushed and hijacked when the writer it wraps can,
+// so streamed responses and websockets still work.
 type loggingWriter struct {
 	http.ResponseWriter
+	// statusCode is the final status of the response, or 0 if nothing has
+	// been written yet.
 	statusCode int
+	// size is how many bytes of the body have been written.
+	size int
+}
+
+// newLoggingWriter wraps w, unless it's already wrapped, since every
+// middleware can share one.
+func newLoggingWriter(w http.ResponseWriter) *loggingWriter {
+	if lw, ok := w.(*loggingWriter); ok {
+		return lw
+	}
+	return &loggingWriter{ResponseWriter: w}
+}
+
+// status is the status the response was sent with. Handlers that don't
+// write anything send 200.
+func (w *loggingWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
 }
 
 func (w *loggingWriter) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
+	// informational responses are followed by the real one.
+	if w.statusCode == 0 && statusCode >= http.StatusOK {
+		w.statusCode = statusCode
+	}
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-func LoggerAndSessions(logger *slog.Logger, logRequests bool) mux.Middleware {
+func (w *loggingWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *loggingWriter) Flush() {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *loggingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the writer that's wrapped.
+func (w *loggingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// accessLog is what the middleware after LoggerAndSessions learn about a
+// request, which is logged once it's answered.
+type accessLog struct {
+	user string
+}
+
+// logUser records the user the request was made by, if it's being logged.
+func logUser(ctx context.Context, user string) {
+	if log, ok := ctx.Value(internal.AccessLog).(*accessLog); ok {
+		log.user = user
+	}
+}
+
+// LoggerAndSessions logs a line for every request once it's answered, for
+// sample of them, between 0 and 1. Server errors are always logged.
+func LoggerAndSessions(logger *slog.Logger, sample float64, sessionCookie *cookie.Codec[cookie.SessionValue]) mux.Middleware {
 	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
+			start := time.Now()
 
 			method, path, requestId := r.Method, r.RequestURI, uuid.NewString()
 			logger := logger.With("requestId", requestId)
 
-			// initialize session cookie if it's missing
+			// logs and traces of the request can be found from each other.
+			if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+				span.SetAttributes(attribute.String("request.id", requestId))
+				logger = logger.With("traceId", span.SpanContext().TraceID().String(),
+					"spanId", span.SpanContext().SpanID().String())
+			}
+
+			// initialize session cookie if it's missing, or can't be trusted
 			var session string
-			if Cookie, err := r.Cookie(cookie.Session.Name); err == http.ErrNoCookie {
-				logger.Debug("setting new session cookie", "value", session)
-				session = uuid.NewString()
-				NewCookie := cookie.Session
-				NewCookie.Value = session
-				http.SetCookie(w, &NewCookie)
-			} else if err != nil {
-				logger.Error("error getting session cookie", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				render.Page(w, r, nil, html.P{"Something went wrong."})
-				return
+			if value, err := sessionCookie.Get(r); err == nil {
+				logger.Debug("got existing session cookie", "value", value.ID)
+				session = value.ID
 			} else {
-				logger.Debug("got existing session cookie", "value", Cookie.Value)
-				session = Cookie.Value
+				if !errors.Is(err, http.ErrNoCookie) {
+					logger.Warn("replacing invalid session cookie", "error", err)
+				}
+				session = uuid.NewString()
+				logger.Debug("setting new session cookie", "value", session)
+				if err := sessionCookie.Set(w, &cookie.SessionValue{ID: session}); err != nil {
+					// the logger isn't in the context yet, but errors are logged with it.
+					render.ServeError(w, r.WithContext(context.WithValue(ctx, internal.Logger, logger)),
+						mux.NewError(http.StatusInternalServerError, "Something went wrong.",
+							fmt.Errorf("setting session cookie: %w", err)))
+					return
+				}
 			}
 
 			logger = logger.With("session", session)
+			log := &accessLog{}
 
+			ctx = context.WithValue(ctx, internal.AccessLog, log)
 			ctx = context.WithValue(ctx, internal.Logger, logger)
 			ctx = context.WithValue(ctx, internal.RequestId, requestId)
 			ctx = context.WithValue(ctx, internal.RequestRef, r)
 			ctx = context.WithValue(ctx, internal.RouterMethod, method)
 			ctx = context.WithValue(ctx, internal.RouterPath, path)
 			ctx = context.WithValue(ctx, internal.Session, session)
+			ctx = context.WithValue(ctx, internal.SessionCookie, sessionCookie)
 			ctx = context.WithValue(ctx, internal.WriterRef, w)
 
-			if logRequests {
-				logger.Info("request received", "method", r.Method, "uri", r.RequestURI)
-			}
-
-			lw := &loggingWriter{ResponseWriter: w}
+			lw := newLoggingWriter(w)
 			next.ServeHTTP(lw, r.WithContext(ctx))
 
-			if logRequests {
-				logger.Debug("request headers sent", "status", lw.statusCode, "headers", lw.Header())
+			status := lw.status()
+			if status < http.StatusInternalServerError && rand.Float64() >= sample {
+				return
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelWarn
 			}
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("method", method),
+				slog.String("uri", path),
+				slog.String("pattern", r.Pattern),
+				slog.Int("status", status),
+				slog.Int("size", lw.size),
+				slog.Float64("durationMs", float64(time.Since(start).Microseconds())/1000),
+				slog.String("ip", utils.ClientIP(r)),
+				slog.String("user", log.user),
+				slog.String("userAgent", r.UserAgent()),
+			)
+			logger.Debug("request headers sent", "headers", lw.Header())
 		})
 	})
 }
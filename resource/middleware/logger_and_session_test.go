@@ -0,0 +1,184 @@
+package middleware_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+)
+
+// logs are written after the response is sent, so they're read once as many
+// lines as expected have been written.
+type logs struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *logs) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(b)
+}
+
+// requests waits for n access log lines, and returns them.
+func (l *logs) requests(n int) []map[string]any {
+	var lines []map[string]any
+	for range 100 {
+		l.mu.Lock()
+		written := strings.TrimSpace(l.buf.String())
+		l.mu.Unlock()
+
+		lines = nil
+		for _, line := range strings.Split(written, "\n") {
+			var entry map[string]any
+			if err := json.Unmarshal([]byte(line), &entry); err == nil && entry["msg"] == "request" {
+				lines = append(lines, entry)
+			}
+		}
+		if len(lines) >= n {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return lines
+}
+
+// serve serves handler at pattern, behind fly's ClientIP, LoggerAndSessions
+// and then middleware, returning what it logs.
+func serve(t *testing.T, sample float64, pattern string, handler http.HandlerFunc, middleware ...mux.Middleware) (*httptest.Server, *logs) {
+	return serveWith(t, httptest.NewServer, sample, pattern, handler, middleware...)
+}
+
+// serveTLS is serve over https, which secure cookies need to be sent back.
+func serveTLS(t *testing.T, sample float64, pattern string, handler http.HandlerFunc, middleware ...mux.Middleware) (*httptest.Server, *logs) {
+	return serveWith(t, httptest.NewTLSServer, sample, pattern, handler, middleware...)
+}
+
+func serveWith(
+	t *testing.T,
+	newServer func(http.Handler) *httptest.Server,
+	sample float64,
+	pattern string,
+	handler http.HandlerFunc,
+	middleware ...mux.Middleware,
+) (*httptest.Server, *logs) {
+	keys, err := symetric.NewKeyring("a very long application secret used for testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &logs{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	var h http.Handler = handler
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i].Use(h)
+	}
+	m := http.NewServeMux()
+	m.Handle(pattern, ClientIP(true).Use(LoggerAndSessions(logger, sample, cookie.NewSession(keys)).Use(h)))
+
+	server := newServer(m)
+	t.Cleanup(server.Close)
+	return server, logs
+}
+
+func TestAccessLog(t *testing.T) {
+	server, logs := serve(t, 1, "GET /events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: one\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected to be able to flush, got %v", err)
+		}
+		w.Write([]byte("data: two\n\n"))
+	})
+
+	req, _ := http.NewRequest("GET", server.URL+"/events/1", nil)
+	req.Header.Set("Fly-Client-IP", "203.0.113.7")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	lines := logs.requests(1)
+	if len(lines) != 1 {
+		t.Fatalf("expected one access log line, got %v", lines)
+	}
+	for key, expected := range map[string]any{
+		"method": "GET", "uri": "/events/1", "pattern": "GET /events/{id}",
+		"status": 200.0, "size": 22.0, "ip": "203.0.113.7",
+	} {
+		if lines[0][key] != expected {
+			t.Errorf("expected %s to be %v, got %v", key, expected, lines[0][key])
+		}
+	}
+	if _, ok := lines[0]["durationMs"].(float64); !ok {
+		t.Errorf("expected a duration, got %v", lines[0]["durationMs"])
+	}
+}
+
+func TestAccessLogSample(t *testing.T) {
+	server, logs := serve(t, 0, "GET /{status}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("status") == "error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	for _, path := range []string{"/ok", "/error"} {
+		res, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	// the server error is logged after the ok request would have been.
+	lines := logs.requests(1)
+	if len(lines) != 1 || lines[0]["status"] != 500.0 {
+		t.Errorf("expected only the server error to be logged, got %v", lines)
+	}
+}
+
+func TestAccessLogHijack(t *testing.T) {
+	server, logs := serve(t, 1, "GET /ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("expected to be able to hijack, got %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	})
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected to switch protocols, got %d", res.StatusCode)
+	}
+
+	if lines := logs.requests(1); len(lines) != 1 || lines[0]["status"] != 101.0 {
+		t.Errorf("expected the hijacked request to be logged, got %v", lines)
+	}
+}
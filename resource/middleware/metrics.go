@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+)
+
+// Metrics records every request, by the pattern it was routed with. It's
+// added first, so that it times every other middleware too.
+func Metrics(metrics *metrics.Service) mux.Middleware {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done := metrics.Request()
+			lw := newLoggingWriter(w)
+			next.ServeHTTP(lw, r)
+
+			// the method is its own label, the pattern is only the path.
+			pattern := r.Pattern
+			if _, path, ok := strings.Cut(pattern, " "); ok {
+				pattern = path
+			}
+			done(r.Method, pattern, lw.status(), lw.size)
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/garrettdavis.dev/utils/ratelimit"
+)
+
+// RateLimitKey is who a request is counted against.
+type RateLimitKey func(r *http.Request) string
+
+var (
+	// RateLimitIP counts requests by the address they came from.
+	RateLimitIP RateLimitKey = func(r *http.Request) string {
+		return "ip:" + utils.ClientIP(r)
+	}
+	// RateLimitSession counts requests by their session.
+	RateLimitSession RateLimitKey = func(r *http.Request) string {
+		return "session:" + access.Session(r.Context())
+	}
+	// RateLimitUser counts requests by the user they're made by, and by the
+	// address they came from when they're anonymous. It must come after
+	// Sessions, or Authorization to count api tokens by their user.
+	RateLimitUser RateLimitKey = func(r *http.Request) string {
+		if actor := access.Actor(r.Context()); actor != "" {
+			return "user:" + actor
+		}
+		return RateLimitIP(r)
+	}
+)
+
+// RateLimit refuses requests once the ones with the same key have used up
+// limit, telling them when to retry. Requests are counted separately for
+// every name, so each group can have its own limit. When the limiter fails,
+// requests are allowed rather than have the site fail with it. An invalid
+// limit is a mistake in the code, so it panics rather than allow everything.
+func RateLimit(limiter ratelimit.Limiter, name string, limit ratelimit.Limit, key RateLimitKey) mux.Middleware {
+	if err := limit.Validate(); err != nil {
+		panic(fmt.Sprintf("middleware.RateLimit %s: %v", name, err))
+	}
+
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			res, err := limiter.Allow(ctx, "ratelimit:"+name+":"+key(r), limit)
+			if err != nil {
+				logger(ctx).With("scope", "middleware.RateLimit").ErrorContext(ctx,
+					"error counting request, allowing it", "limit", name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			} else if !res.Allowed {
+				retry := int(math.Ceil(res.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				render.ServeError(w, r, mux.NewError(http.StatusTooManyRequests,
+					fmt.Sprintf("You're doing that too often, please try again in %d seconds.", retry),
+					fmt.Errorf("over the %s limit", name)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
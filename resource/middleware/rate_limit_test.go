@@ -0,0 +1,50 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/utils/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	server, _ := serve(t, 0, "POST /contact", func(w http.ResponseWriter, r *http.Request) {},
+		RateLimit(ratelimit.NewMemory(), "contact", ratelimit.PerMinute(1, 2), RateLimitIP))
+
+	post := func(ip string) *http.Response {
+		req, _ := http.NewRequest("POST", server.URL+"/contact", nil)
+		req.Header.Set("Fly-Client-IP", ip)
+		req.Header.Set("HX-Request", "true")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for range 2 {
+		if res := post("203.0.113.7"); res.StatusCode != http.StatusOK {
+			t.Fatalf("expected the burst to be allowed, got %d", res.StatusCode)
+		}
+	}
+
+	res := post("203.0.113.7")
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", res.StatusCode)
+	}
+	if retry := res.Header.Get("Retry-After"); retry != "60" {
+		t.Errorf("expected to retry after 60 seconds, got %q", retry)
+	}
+	if !strings.HasPrefix(string(body), "<span") || !strings.Contains(string(body), "60 seconds") {
+		t.Errorf("expected a fragment saying when to retry, got %q", body)
+	}
+
+	if res := post("203.0.113.8"); res.StatusCode != http.StatusOK {
+		t.Errorf("expected other addresses to have their own limit, got %d", res.StatusCode)
+	}
+}
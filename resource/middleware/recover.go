@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	html "github.com/Gardego5/htmdsl"
+)
+
+// ErrorSink is told about every panic that's recovered from, such as to
+// count them or send them to an error tracker.
+type ErrorSink interface {
+	Report(ctx context.Context, err error, stack []byte)
+}
+
+// Recover answers requests whose handler panics with an error page, rather
+// than dropping the connection, logging the panic with its stack and
+// reporting it to sink, which may be nil. When the response has already
+// been started it can't be replaced, so the connection is aborted instead.
+// It must come after Inject, since the error page needs what it injects.
+func Recover(sink ErrorSink) mux.Middleware {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lw := newLoggingWriter(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				} else if v == http.ErrAbortHandler {
+					// aborting is how a handler asks for the connection to
+					// be dropped, which the server does quietly.
+					panic(v)
+				}
+
+				ctx := r.Context()
+				stack := debug.Stack()
+				err, ok := v.(error)
+				if !ok {
+					err = fmt.Errorf("%v", v)
+				}
+				logger(ctx).With("scope", "middleware.Recover").ErrorContext(ctx,
+					"recovered from panic", "error", err, "stack", string(stack))
+				if sink != nil {
+					sink.Report(ctx, err, stack)
+				}
+
+				if lw.statusCode != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				internalError(lw, r)
+			}()
+
+			next.ServeHTTP(lw, r)
+		})
+	})
+}
+
+// internalError replaces a response that hasn't been started yet with an
+// error. What the handler set to describe its own response is dropped.
+func internalError(w http.ResponseWriter, r *http.Request) {
+	for _, key := range []string{
+		"Content-Disposition", "Content-Encoding", "Content-Length", "Content-Type",
+		"ETag", "Last-Modified", "HX-Location", "HX-Redirect", "HX-Refresh", "HX-Trigger",
+	} {
+		w.Header().Del(key)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	if wantsJSON(r) {
+		render.Error(w, render.ErrorDetail{
+			Status:  http.StatusInternalServerError,
+			Message: "An error has occurred.",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	if r.Header.Get("HX-Request") == "true" {
+		html.RenderContext(w, r.Context(), html.P{"Something went wrong, please try again."})
+		return
+	}
+	render.Page(w, r, nil,
+		components.Header{Title: "Error"},
+		components.Margins{html.P{"Something went wrong on our end, please try again later."}})
+}
@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+)
+
+type sink struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (s *sink) Report(ctx context.Context, err error, stack []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+func TestRecover(t *testing.T) {
+	sink := &sink{}
+	server, logs := serve(t, 1, "GET /{kind}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		if r.PathValue("kind") == "started" {
+			w.Write([]byte("a,b\n"))
+			http.NewResponseController(w).Flush()
+		}
+		panic("something broke")
+	},
+		Inject(Syringe(utils.Ptr(render.StaticPathPrefix("/static")))),
+		Recover(sink),
+	)
+
+	for _, test := range []struct {
+		name, path string
+		header     map[string]string
+		body       string
+		typ        string
+	}{
+		{"page", "/page", nil, "Something went wrong on our end", "text/html"},
+		{"htmx", "/htmx", map[string]string{"HX-Request": "true"}, "<p>Something went wrong, please try again.</p>", "text/html"},
+		{"api", "/api", map[string]string{"Accept": "application/json"}, `"code":"internal_server_error"`, "application/json"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", server.URL+test.path, nil)
+			for k, v := range test.header {
+				req.Header.Set(k, v)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusInternalServerError {
+				t.Errorf("expected status 500, got %d", res.StatusCode)
+			}
+			if typ := res.Header.Get("Content-Type"); !strings.HasPrefix(typ, test.typ) {
+				t.Errorf("expected content type %s, got %s", test.typ, typ)
+			}
+			if !strings.Contains(string(body), test.body) {
+				t.Errorf("expected body to contain %q, got %q", test.body, body)
+			}
+		})
+	}
+
+	// a response that's been started can't be replaced, so it's cut short.
+	res, err := http.Get(server.URL + "/started")
+	if err == nil {
+		_, err = io.ReadAll(res.Body)
+		res.Body.Close()
+	}
+	if err == nil {
+		t.Error("expected the started response to be aborted")
+	}
+
+	if lines := logs.requests(3); len(lines) != 3 {
+		t.Errorf("expected the answered requests to be logged, got %v", lines)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.errs) != 4 || sink.errs[0].Error() != "something broke" {
+		t.Errorf("expected every panic to be reported, got %v", sink.errs)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/internal"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+)
+
+// Sessions loads the server side session of the request, if it has one, and
+// keeps it alive while it is in use. It must come after LoggerAndSessions.
+func Sessions(sessions *session.Service, sessionCookie *cookie.Codec[cookie.SessionValue]) mux.Middleware {
+	return mux.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := logger(ctx).With("scope", "middleware.Sessions")
+
+			data, err := sessions.Get(ctx, access.Session(ctx))
+			if errors.Is(err, bimarshal.ErrNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			} else if err != nil {
+				logger.ErrorContext(ctx, "error loading session", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if touched, err := sessions.Seen(ctx, data, r); err != nil {
+				logger.ErrorContext(ctx, "error refreshing session", "error", err)
+			} else if touched && data.User != "" {
+				// slide the cookie along with the server side expiry
+				err := sessionCookie.WithMaxAge(sessions.IdleTimeout()).Set(w, &cookie.SessionValue{ID: data.ID})
+				if err != nil {
+					logger.ErrorContext(ctx, "error setting session cookie", "error", err)
+				}
+			}
+
+			logUser(ctx, data.User)
+			ctx = context.WithValue(ctx, internal.SessionData, data)
+			ctx = context.WithValue(ctx, internal.Flashes, sync.OnceValue(func() []string {
+				flashes, err := sessions.Flashes(ctx, data)
+				if err != nil {
+					logger.ErrorContext(ctx, "error clearing flash messages", "error", err)
+				}
+				return flashes
+			}))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
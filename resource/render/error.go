@@ -1 +1,74 @@
 package render
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/htmdsl"
+)
+
+type (
+	// ErrorResponse is the envelope every error from the api is sent in.
+	ErrorResponse struct {
+		Error ErrorDetail `json:"error"`
+	}
+	ErrorDetail struct {
+		Status int `json:"status"`
+		// Code is a stable, machine readable description of the error.
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		// Fields describes what's wrong with each invalid field of a request.
+		Fields map[string]string `json:"fields,omitempty"`
+	}
+)
+
+// JSON sends v as the body of a response with status.
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Error sends err as json. The code defaults to the status text, like
+// "not_found".
+func Error(w http.ResponseWriter, err ErrorDetail) {
+	if err.Code == "" {
+		err.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(err.Status)), " ", "_")
+	}
+	JSON(w, err.Status, ErrorResponse{Error: err})
+}
+
+// ServeError answers a request that failed with err, in whatever form it was
+// asking for: json for the api, a fragment for htmx, and a page otherwise.
+// Only the message of a mux.Error is shown, anything else is logged as an
+// unexpected error.
+func ServeError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	e := mux.AsError(err)
+
+	level := slog.LevelWarn
+	if e.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	access.Logger(ctx, "ServeError").Log(ctx, level, "Error handling request",
+		"pattern", r.Pattern, "status", e.Status, "error", err)
+
+	if access.API(ctx) {
+		Error(w, ErrorDetail{Status: e.Status, Message: e.Message, Fields: e.Fields})
+		return
+	}
+
+	w.WriteHeader(e.Status)
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-Boosted") == "" {
+		html.RenderContext(w, ctx, html.Span{html.Class("text-red-400"), e.Message})
+		return
+	}
+	Page(w, r, nil,
+		components.Header{},
+		components.Margins(html.P{e.Message}))
+}
@@ -16,6 +16,13 @@ func Page(w http.ResponseWriter, r *http.Request, head html.HTML, body ...any) {
 
 	prefix := string(*access.Get[StaticPathPrefix](ctx))
 	boosted := r.Header.Get("hx-boosted") == "true"
+	// the token is only shown to sessions that already have one, pages don't
+	// start a session just by being viewed. forms start one when they need it.
+	csrf := ""
+	if session := access.SessionData(ctx); session != nil {
+		csrf = session.CSRFToken
+	}
+	flashes := access.Flashes(ctx)
 
 	html.RenderContext(w, ctx, html.Fragment{
 		html.DOCTYPE,
@@ -23,6 +30,11 @@ func Page(w http.ResponseWriter, r *http.Request, head html.HTML, body ...any) {
 			html.Head{
 				head,
 
+				// the head is replaced on every navigation, unlike the body's
+				// attributes, so this always holds the token of the current
+				// session.
+				util.If(csrf != "", html.Meta{"name": "csrf-token", "content": csrf}),
+
 				util.If(!boosted,
 					// Meta Tags
 					html.Meta{"charset": "utf-8", "hx-preserve": true},
@@ -53,6 +65,10 @@ func Page(w http.ResponseWriter, r *http.Request, head html.HTML, body ...any) {
 						}}
 					}),
 
+					// this is how the csrf token gets to the server for
+					// htmx requests like hx-delete.
+					html.Script{html.Attrs{"hx-preserve": true}, html.PreEscaped(csrfScript)},
+
 					// Even though we want to defer the execution of these
 					// scripts, we don't want to delay it's loading.
 					pie.Map([]string{
@@ -80,9 +96,19 @@ func Page(w http.ResponseWriter, r *http.Request, head html.HTML, body ...any) {
 			html.Body{html.Class("box-border bg-zinc-50 dark:bg-zinc-950 text-zinc-950 dark:text-zinc-50"),
 				html.Attrs{"hx-ext": "response-targets,head-support", "hx-boost": true},
 				html.Div{html.Class("print:bg-white bg-zinc-100 dark:bg-zinc-900 min-h-[100vh]"),
+					util.If(len(flashes) > 0, html.Div{
+						html.Class("print:hidden px-4 py-2 bg-zinc-200 dark:bg-zinc-800"),
+						html.Attrs{"role": "status"},
+						pie.Map(flashes, func(message string) any { return html.P{message} }),
+					}),
 					body,
 				},
 			},
 		},
 	})
 }
+
+const csrfScript = `document.addEventListener("htmx:configRequest", (event) => {
+	const token = document.querySelector('meta[name="csrf-token"]');
+	if (token) event.detail.headers["X-CSRF-Token"] = token.content;
+});`
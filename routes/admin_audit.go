@@ -0,0 +1,229 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	. "github.com/Gardego5/htmdsl"
+	. "github.com/Gardego5/htmdsl/util"
+	"github.com/elliotchance/pie/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/monoculum/formam"
+)
+
+// recordAudit records event, only logging if it can't be. What's being
+// audited has already happened, so it isn't undone.
+func recordAudit(ctx context.Context, audit *audit.Service, event model.AuditEvent) {
+	if err := audit.Record(ctx, event); err != nil {
+		access.Logger(ctx, "Audit").Error("Error recording audit event", "error", err, "event", event)
+	}
+}
+
+type AdminAudit struct {
+	audit *audit.Service
+}
+
+func NewAdminAudit(
+	audit *audit.Service,
+) *AdminAudit {
+	return &AdminAudit{audit: audit}
+}
+
+type adminAuditQuery struct {
+	Actor  string `q:"actor"`
+	Action string `q:"action"`
+	Result string `q:"result"`
+	Since  string `q:"since"  validate:"omitempty,datetime=2006-01-02"`
+	Until  string `q:"until"  validate:"omitempty,datetime=2006-01-02"`
+	Limit  int    `q:"limit"  validate:"min=1,max=500"`
+	Offset int    `q:"offset" validate:"min=0"`
+}
+
+// input reads the filters of the query, both dates are inclusive.
+func (q *adminAuditQuery) input() *audit.ListEventsInput {
+	in := &audit.ListEventsInput{
+		Actor:  q.Actor,
+		Action: model.AuditAction(q.Action),
+		Result: model.AuditResult(q.Result),
+		Limit:  q.Limit,
+		Offset: q.Offset,
+	}
+	if t, err := time.Parse(time.DateOnly, q.Since); err == nil {
+		in.Since = t
+	}
+	if t, err := time.Parse(time.DateOnly, q.Until); err == nil {
+		in.Until = t.AddDate(0, 0, 1)
+	}
+	return in
+}
+
+func (q adminAuditQuery) values(offset int) url.Values {
+	values := url.Values{}
+	for k, v := range map[string]string{
+		"actor": q.Actor, "action": q.Action, "result": q.Result,
+		"since": q.Since, "until": q.Until,
+	} {
+		if v != "" {
+			values.Set(k, v)
+		}
+	}
+	values.Set("limit", strconv.Itoa(q.Limit))
+	values.Set("offset", strconv.Itoa(offset))
+	return values
+}
+
+func (h *AdminAudit) query(r *http.Request) (adminAuditQuery, error) {
+	ctx := r.Context()
+	q := adminAuditQuery{Limit: 50}
+	r.ParseForm()
+	access.Get[formam.Decoder](ctx).Decode(r.Form, &q)
+	return q, access.Get[validator.Validate](ctx).Struct(q)
+}
+
+func (h *AdminAudit) GET(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	q, err := h.query(r)
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "Those filters don't make sense.", err)
+	}
+
+	events, err := h.audit.ListEvents(ctx, q.input())
+	if err != nil {
+		return fmt.Errorf("listing audit events: %w", err)
+	}
+
+	count, err := h.audit.CountEvents(ctx, q.input())
+	if err != nil {
+		return fmt.Errorf("counting audit events: %w", err)
+	}
+
+	options := func(selected string, values []string) any {
+		return Fragment{
+			Option{Attrs{"value": ""}, "any"},
+			pie.Map(values, func(v string) any {
+				return Option{Attrs{"value": v, "selected": AttrIf(v == selected)}, v}
+			}),
+		}
+	}
+	cell := "border-b border-slate-500 px-2 py-1 text-left"
+
+	render.Page(w, r, nil, components.Header{Title: "Audit"}, components.Margins{
+		Form{Class("flex flex-wrap items-end gap-4 pb-4"), Attrs{"method": "GET", "action": "/admin/audit"},
+			Label{Class("grid"), "Actor",
+				Input{"class": adminInputClass, "name": "actor", "value": q.Actor}},
+			Label{Class("grid"), "Action",
+				Select{Class(adminInputClass), Attrs{"name": "action"},
+					options(q.Action, pie.Map(model.AuditActions, func(a model.AuditAction) string { return string(a) }))}},
+			Label{Class("grid"), "Result",
+				Select{Class(adminInputClass), Attrs{"name": "result"},
+					options(q.Result, pie.Map(model.AuditResults, func(r model.AuditResult) string { return string(r) }))}},
+			Label{Class("grid"), "Since",
+				Input{"class": adminInputClass, "type": "date", "name": "since", "value": q.Since}},
+			Label{Class("grid"), "Until",
+				Input{"class": adminInputClass, "type": "date", "name": "until", "value": q.Until}},
+			Input{"type": "hidden", "name": "limit", "value": q.Limit},
+			Button{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-blue-800"),
+				Attrs{"type": "submit"}, "Filter"},
+			A{Class("underline"), Attrs{
+				"href":     "/admin/audit/csv?" + q.values(0).Encode(),
+				"download": nil,
+				"hx-boost": "false",
+			}, "Export CSV"},
+		},
+
+		Table{Class("w-full text-sm"),
+			Thead{Tr{
+				Th{Class(cell), "Time"}, Th{Class(cell), "Actor"}, Th{Class(cell), "Action"},
+				Th{Class(cell), "Target"}, Th{Class(cell), "Result"}, Th{Class(cell), "IP"},
+				Th{Class(cell), "Request"},
+			}},
+			Tbody{pie.Map(events, func(e model.AuditEvent) any {
+				result := "text-red-400"
+				if e.Result == model.AuditSuccess {
+					result = "text-green-400"
+				}
+				return Tr{
+					Td{Class(cell, "whitespace-nowrap"), e.CreatedAt.Time.Format(time.RFC1123Z)},
+					Td{Class(cell), If(e.Actor != "", e.Actor).Else(Span{Class("text-gray-400"), "anonymous"})},
+					Td{Class(cell), Code{string(e.Action)}},
+					Td{Class(cell, "break-all"), e.Target},
+					Td{Class(cell, result), string(e.Result)},
+					Td{Class(cell), Code{e.IP}},
+					Td{Class(cell, "text-gray-400 text-xs"), Code{e.RequestID}},
+				}
+			})},
+		},
+
+		Div{Class("flex justify-center gap-4 pt-4"),
+			If(q.Offset > 0, func() any {
+				return A{Attrs{"href": "/admin/audit?" + q.values(max(q.Offset-q.Limit, 0)).Encode()}, "<"}
+			}),
+			P{Class("text-gray-400"),
+				If(count > 0, q.Offset+1).Else(0), " - ", q.Offset + len(events), " of ", count},
+			If(q.Offset+len(events) < count, func() any {
+				return A{Attrs{"href": "/admin/audit?" + q.values(q.Offset+q.Limit).Encode()}, ">"}
+			}),
+		},
+	})
+	return nil
+}
+
+// csvCell keeps spreadsheets from treating a value, which may have come
+// from anyone, as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// GetCSV exports every event matching the filters, ignoring pagination.
+func (h *AdminAudit) GetCSV(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "GetAdminAuditCSV")
+
+	q, err := h.query(r)
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "Those filters don't make sense.", err)
+	}
+
+	input := q.input()
+	input.Limit, input.Offset = 0, 0
+	events, err := h.audit.ListEvents(ctx, input)
+	if err != nil {
+		return fmt.Errorf("listing audit events: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="audit-`+time.Now().UTC().Format("20060102T150405Z")+`.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor", "action", "target", "result", "ip", "request_id"})
+	for _, e := range events {
+		out.Write(pie.Map([]string{
+			strconv.Itoa(e.ID), e.CreatedAt.Time.Format(time.RFC3339), e.Actor, string(e.Action),
+			e.Target, string(e.Result), e.IP, e.RequestID,
+		}, csvCell))
+	}
+	out.Flush()
+
+	// the response has been started, so it's too late to send an error.
+	if err := out.Error(); err != nil {
+		logger.Error("Error writing csv", "error", err)
+	}
+	return nil
+}
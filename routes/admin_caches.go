@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	. "github.com/Gardego5/htmdsl"
+	"github.com/elliotchance/pie/v2"
+)
+
+type AdminCaches struct {
+	caches bimarshal.RegisteredCaches
+}
+
+func NewAdminCaches(
+	caches bimarshal.RegisteredCaches,
+) *AdminCaches {
+	return &AdminCaches{caches: caches}
+}
+
+func (h *AdminCaches) row(ctx context.Context, logger *slog.Logger, c bimarshal.Registered) any {
+	var count any = "?"
+	if keys, err := c.Keys(ctx, ""); err != nil {
+		logger.Error("Error listing cache keys", "prefix", c.Prefix(), "error", err)
+	} else {
+		count = len(keys)
+	}
+
+	return Tr{Class("border-b border-slate-500 [&>td]:px-2 [&>td]:py-1"),
+		Td{Code{c.Prefix()}},
+		Td{c.Codec()},
+		Td{Code{c.Type().String()}},
+		Td{Class("text-right"), count},
+		Td{Form{Class("flex gap-2 justify-end"),
+			Attrs{
+				"hx-delete":  fmt.Sprintf("/admin/caches/%s", c.Prefix()),
+				"hx-target":  "closest tr",
+				"hx-swap":    "outerHTML",
+				"hx-confirm": fmt.Sprintf("Purge matching entries from %s?", c.Prefix()),
+			},
+			Input{
+				"class":       "px-2 rounded-sm border bg-zinc-100 dark:bg-zinc-900 border-slate-500",
+				"name":        "match",
+				"placeholder": "key prefix",
+				"type":        "text",
+			},
+			Button{
+				Class("rounded-sm border border-slate-500 px-2 hover:bg-red-800"),
+				Attrs{"type": "submit"},
+				"purge",
+			},
+		}},
+	}
+}
+
+func (h *AdminCaches) GET(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "GetAdminCaches")
+
+	render.Page(w, r, nil, components.Header{Title: "Caches"}, components.Margins{
+		Table{Class("w-full text-left"),
+			Thead{Tr{Class("border-b border-slate-500 [&>th]:px-2"),
+				Th{"Prefix"}, Th{"Codec"}, Th{"Type"}, Th{Class("text-right"), "Keys"}, Th{},
+			}},
+			Tbody{pie.Map(h.caches.List(), func(c bimarshal.Registered) any {
+				return h.row(ctx, logger, c)
+			})},
+		},
+	})
+}
+
+func (h *AdminCaches) DELETE(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAdminCaches")
+
+	c, ok := h.caches.Lookup(r.PathValue("prefix"))
+	if !ok {
+		return mux.NewError(http.StatusNotFound, "There's no cache with that prefix.", nil)
+	}
+
+	match := r.FormValue("match")
+	keys, err := c.Keys(ctx, match)
+	if err != nil {
+		return fmt.Errorf("listing keys of %s: %w", c.Prefix(), err)
+	}
+
+	if err = c.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("purging %s: %w", c.Prefix(), err)
+	}
+
+	logger.Info("Cache purged", "prefix", c.Prefix(), "match", match, "count", len(keys))
+	RenderContext(w, ctx, h.row(ctx, logger, c))
+	return nil
+}
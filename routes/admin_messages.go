@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,7 +11,9 @@ import (
 	"github.com/Gardego5/garrettdavis.dev/model"
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
 	"github.com/Gardego5/garrettdavis.dev/service/messages"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
 	. "github.com/Gardego5/htmdsl"
 	"github.com/elliotchance/pie/v2"
 	"github.com/go-playground/validator/v10"
@@ -19,15 +22,17 @@ import (
 
 type AdminMessages struct {
 	messages *messages.Service
+	audit    *audit.Service
 }
 
 func NewAdminMessages(
 	messages *messages.Service,
+	audit *audit.Service,
 ) *AdminMessages {
-	return &AdminMessages{messages: messages}
+	return &AdminMessages{messages: messages, audit: audit}
 }
 
-func (h *AdminMessages) GET(w http.ResponseWriter, r *http.Request) {
+func (h *AdminMessages) GET(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	logger := access.Logger(ctx, "GetAdminUser")
 
@@ -39,9 +44,7 @@ func (h *AdminMessages) GET(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	access.Get[formam.Decoder](ctx).Decode(r.Form, &q)
 	if err := access.Get[validator.Validate](ctx).Struct(q); err != nil {
-		logger.Error("Error validating form", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return mux.NewError(http.StatusBadRequest, "The sort, limit or offset is invalid.", err)
 	}
 
 	logger.Info("Listing messages", "query", q)
@@ -49,16 +52,12 @@ func (h *AdminMessages) GET(w http.ResponseWriter, r *http.Request) {
 	msgs, err := h.messages.ListMessages(ctx, &messages.ListMessageInput{
 		Sort: q.Sort, Limit: q.Limit, Offset: q.Offset})
 	if err != nil {
-		logger.Error("Error listing messages", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return fmt.Errorf("listing messages: %w", err)
 	}
 
 	count, err := h.messages.CountMessages(ctx)
 	if err != nil {
-		logger.Error("Error counting messages", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return fmt.Errorf("counting messages: %w", err)
 	}
 
 	list := Ul{Class("grid grid-cols-1 gap-6"),
@@ -179,27 +178,31 @@ func (h *AdminMessages) GET(w http.ResponseWriter, r *http.Request) {
 			list,
 		})
 	}
+	return nil
 }
 
-func (h *AdminMessages) DELETE(w http.ResponseWriter, r *http.Request) {
+func (h *AdminMessages) DELETE(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	logger := access.Logger(ctx, "DeleteAdminMessage")
 
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		logger.Error("Error parsing id", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return mux.NewError(http.StatusBadRequest, "The id must be a number.", err)
 	}
 
+	event := access.AuditEvent(ctx, model.AuditDeleteMessage, strconv.FormatInt(id, 10), model.AuditSuccess)
 	if err = h.messages.DeleteMessage(ctx, &messages.DeleteMessageInput{
 		ID: int(id),
-	}); err != nil {
-		logger.Error("Error deleting message", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	}); errors.Is(err, messages.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "There's no message with that id.", err)
+	} else if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("deleting message: %w", err)
 	}
 
+	recordAudit(ctx, h.audit, event)
 	logger.Info("Message deleted", "id", id)
 	w.WriteHeader(http.StatusOK)
+	return nil
 }
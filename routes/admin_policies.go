@@ -0,0 +1,449 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/policies"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	. "github.com/Gardego5/htmdsl"
+	. "github.com/Gardego5/htmdsl/util"
+	"github.com/elliotchance/pie/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+type AdminPolicies struct {
+	policies *policies.Service
+	// watcher is nil when there's no redis to sync policies through.
+	watcher  *policies.Watcher
+	audit    *audit.Service
+	validate *validator.Validate
+}
+
+func NewAdminPolicies(
+	policies *policies.Service,
+	watcher *policies.Watcher,
+	audit *audit.Service,
+	validate *validator.Validate,
+) *AdminPolicies {
+	return &AdminPolicies{policies: policies, watcher: watcher, audit: audit, validate: validate}
+}
+
+const adminInputClass = "rounded-sm border border-slate-500 bg-zinc-900 px-2 py-1 font-mono text-sm"
+
+func policyRow(p model.Policy) any {
+	return Li{Class("relative rounded-sm border border-slate-500 bg-gray-800 p-4",
+		"[&.htmx-swapping]:transition-opacity [&.htmx-swapping]:opacity-0 list-none",
+	),
+		components.Form{Class("grid grid-cols-1 md:grid-cols-[1fr_12rem_6rem_auto] gap-2"),
+			Attrs{
+				"hx-put":          "/admin/policies",
+				"hx-target":       "closest li",
+				"hx-swap":         "outerHTML swap:0.1s",
+				"hx-target-error": "#policy-error",
+			},
+			Input{"type": "hidden", "name": "old_sub_rule", "value": p.SubRule},
+			Input{"type": "hidden", "name": "old_obj", "value": p.Obj},
+			Input{"type": "hidden", "name": "old_act", "value": p.Act},
+
+			Input{"class": adminInputClass, "name": "sub_rule", "value": p.SubRule, "required": nil},
+			Input{"class": adminInputClass, "name": "obj", "value": p.Obj, "required": nil},
+			Input{"class": adminInputClass, "name": "act", "value": p.Act, "required": nil},
+
+			Div{Class("flex gap-2"),
+				Button{
+					Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-green-800 grid place-items-center"),
+					Attrs{"type": "submit", "title": "Save"},
+					Element("iconify-icon", Attrs{"icon": "mdi:content-save-outline", "width": 20, "height": 20}),
+				},
+				Button{
+					Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-red-800 grid place-items-center"),
+					Attrs{
+						"type":       "button",
+						"title":      "Delete",
+						"hx-delete":  "/admin/policies",
+						"hx-include": "closest form",
+						"hx-confirm": "Delete this policy?",
+					},
+					Element("iconify-icon", Attrs{"icon": "mdi:delete-outline", "width": 20, "height": 20}),
+				},
+			},
+		},
+	}
+}
+
+func roleRow(a model.RoleAssignment) any {
+	return Li{Class("list-none rounded-sm border border-slate-500 bg-gray-800 px-4 py-2 flex justify-between items-center gap-2",
+		"[&.htmx-swapping]:transition-opacity [&.htmx-swapping]:opacity-0",
+	),
+		Span{Code{a.Subject}, Span{Class("text-gray-400"), " has "}, Code{a.Role}},
+		Button{
+			Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-red-800 grid place-items-center"),
+			Attrs{
+				"type":            "button",
+				"title":           "Unassign",
+				"hx-delete":       "/admin/policies/roles",
+				"hx-vals":         fmt.Sprintf(`{"subject": %q, "role": %q}`, a.Subject, a.Role),
+				"hx-target":       "closest li",
+				"hx-swap":         "outerHTML swap:0.1s",
+				"hx-target-error": "#role-error",
+				"hx-confirm":      fmt.Sprintf("Take %s away from %s?", a.Role, a.Subject),
+			},
+			Element("iconify-icon", Attrs{"icon": "mdi:delete-outline", "width": 20, "height": 20}),
+		},
+	}
+}
+
+func policyStatus(status policies.WatcherStatus) any {
+	return P{Class("text-gray-400 text-sm pb-4"),
+		"Policy version ", status.Version,
+		If(!status.ReloadedAt.IsZero(), func() any {
+			return Fragment{", reloaded ", status.ReloadedAt.Format(time.RFC1123Z)}
+		}), ".",
+		If(status.Error != "", func() any {
+			return Span{Class("text-red-400"),
+				" Syncing has failed ", status.Failures, " times in a row, the last at ",
+				status.FailedAt.Format(time.RFC1123Z), ": ", status.Error}
+		}),
+	}
+}
+
+func policyChanges(changes []model.PolicyChange) Ul {
+	rule := func(p model.NullPolicy) any {
+		return If(p.Valid, func() any {
+			return Code{Class("text-xs"), p.SubRule, ", ", p.Obj, ", ", p.Act}
+		})
+	}
+
+	role := func(a model.NullRoleAssignment) any {
+		return Code{Class("text-xs"), a.Subject, " has ", a.Role}
+	}
+
+	return Ul{Id("policy-changes"), Class("grid grid-cols-1 gap-2"),
+		pie.Map(changes, func(c model.PolicyChange) any {
+			return Li{Class("list-none rounded-sm border border-slate-500 bg-gray-800 px-4 py-2 grid gap-1"),
+				Div{Class("flex justify-between gap-2 text-sm"),
+					Span{c.Actor, " ", Span{Class("text-gray-400"), string(c.Action)}},
+					Span{Class("text-gray-400 text-xs"), c.CreatedAt.Time.Format(time.RFC1123Z)},
+				},
+				If(c.OldRule.Valid, Div{Class("text-red-400"), "- ", rule(c.OldRule)}),
+				If(c.NewRule.Valid, Div{Class("text-green-400"), "+ ", rule(c.NewRule)}),
+				If(c.Role.Valid && c.Action == model.PolicyActionAssign, Div{Class("text-green-400"), "+ ", role(c.Role)}),
+				If(c.Role.Valid && c.Action == model.PolicyActionUnassign, Div{Class("text-red-400"), "- ", role(c.Role)}),
+			}
+		}),
+	}
+}
+
+// changed renders the recent changes out of band, so they stay up to date
+// as policies are edited.
+func (h *AdminPolicies) changed(w http.ResponseWriter, r *http.Request, rest ...any) {
+	ctx := r.Context()
+
+	changes, err := h.policies.Changes(ctx, 20)
+	if err != nil {
+		access.Logger(ctx, "AdminPolicies").Error("Error listing policy changes", "error", err)
+	}
+
+	RenderContext(w, ctx, Fragment{
+		rest,
+		P{Id("policy-error"), Class("text-red-400 pb-2"), Attrs{"hx-swap-oob": true}},
+		append(policyChanges(changes), Attrs{"hx-swap-oob": true}),
+	})
+}
+
+// policyForm reads a policy from the form, fields are prefixed by prefix.
+func (h *AdminPolicies) policyForm(r *http.Request, prefix string) (model.Policy, error) {
+	p := model.Policy{
+		SubRule: r.FormValue(prefix + "sub_rule"),
+		Obj:     r.FormValue(prefix + "obj"),
+		Act:     r.FormValue(prefix + "act"),
+	}
+	return p, h.validate.Struct(p)
+}
+
+// roleForm reads a role assignment from the form.
+func (h *AdminPolicies) roleForm(r *http.Request) (model.RoleAssignment, error) {
+	a := model.RoleAssignment{
+		Subject: strings.TrimSpace(r.FormValue("subject")),
+		Role:    strings.TrimSpace(r.FormValue("role")),
+	}
+	return a, h.validate.Struct(a)
+}
+
+func (h *AdminPolicies) GET(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	list, err := h.policies.List()
+	if err != nil {
+		return fmt.Errorf("listing policies: %w", err)
+	}
+
+	roles, err := h.policies.Roles()
+	if err != nil {
+		return fmt.Errorf("listing roles: %w", err)
+	}
+
+	changes, err := h.policies.Changes(ctx, 20)
+	if err != nil {
+		return fmt.Errorf("listing policy changes: %w", err)
+	}
+
+	render.Page(w, r, nil, components.Header{Title: "Policies"}, components.Margins{
+		If(h.watcher != nil, func() any { return policyStatus(h.watcher.Status()) }).
+			Else(P{Class("text-gray-400 text-sm pb-4"), "Policies aren't synced with other machines, there's no redis."}),
+
+		P{Class("text-gray-400 text-sm pb-4"),
+			"A request is allowed when ", Code{"eval(sub_rule) && keyMatch4(r.obj, obj) && r.act == act"},
+			" holds for any policy. The subject is ", Code{"r.sub.User"}, ", ", Code{"r.sub.Provider"},
+			" and ", Code{"r.sub.Groups"}, ", and ", Code{`hasRole(r.sub, "admin")`},
+			" checks the roles given by g rules.",
+		},
+
+		P{Id("policy-error"), Class("text-red-400 pb-2")},
+
+		Ul{Id("policies"), Class("grid grid-cols-1 gap-4"),
+			pie.Map(list, policyRow),
+		},
+
+		H2{Class("text-xl pt-8 pb-2"), "Add"},
+		components.Form{Class("grid grid-cols-1 md:grid-cols-[1fr_12rem_6rem_auto] gap-2"),
+			Attrs{
+				"hx-post":              "/admin/policies",
+				"hx-target":            "#policies",
+				"hx-swap":              "beforeend",
+				"hx-target-error":      "#policy-error",
+				"hx-on::after-request": "if (event.detail.successful) this.reset()",
+			},
+			Input{"class": adminInputClass, "name": "sub_rule", "placeholder": `r.sub.User == "someone"`, "required": nil},
+			Input{"class": adminInputClass, "name": "obj", "placeholder": "/admin/*", "required": nil},
+			Input{"class": adminInputClass, "name": "act", "placeholder": "GET", "required": nil},
+			Button{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-green-800"),
+				Attrs{"type": "submit"}, "Add"},
+		},
+
+		H2{Class("text-xl pt-8 pb-2"), "Roles"},
+		P{Class("text-gray-400 text-sm pb-4"),
+			"Roles are given to a user, like ", Code{"someone"}, ", or to everyone in a github organization or team, like ",
+			Code{"@org"}, " or ", Code{"@org/team"}, ".",
+		},
+		P{Id("role-error"), Class("text-red-400 pb-2")},
+		Ul{Id("roles"), Class("grid grid-cols-1 gap-2 pb-4"),
+			pie.Map(roles, roleRow),
+		},
+		components.Form{Class("grid grid-cols-1 md:grid-cols-[1fr_12rem_auto] gap-2"),
+			Attrs{
+				"hx-post":              "/admin/policies/roles",
+				"hx-target":            "#roles",
+				"hx-swap":              "beforeend",
+				"hx-target-error":      "#role-error",
+				"hx-on::after-request": "if (event.detail.successful) this.reset()",
+			},
+			Input{"class": adminInputClass, "name": "subject", "placeholder": "someone, @org or @org/team", "required": nil},
+			Input{"class": adminInputClass, "name": "role", "placeholder": "admin", "required": nil},
+			Button{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-green-800"),
+				Attrs{"type": "submit"}, "Assign"},
+		},
+
+		H2{Class("text-xl pt-8 pb-2"), "Test"},
+		components.Form{Class("grid grid-cols-1 md:grid-cols-[8rem_1fr_1fr_12rem_6rem_auto] gap-2"),
+			Attrs{
+				"hx-post":         "/admin/policies/test",
+				"hx-target":       "#policy-test",
+				"hx-target-error": "#policy-test",
+			},
+			Input{"class": adminInputClass, "name": "provider", "value": model.ProviderGithub, "required": nil},
+			Input{"class": adminInputClass, "name": "user", "placeholder": "user", "required": nil},
+			Input{"class": adminInputClass, "name": "groups", "placeholder": "@org @org/team"},
+			Input{"class": adminInputClass, "name": "obj", "placeholder": "/admin/messages", "required": nil},
+			Input{"class": adminInputClass, "name": "act", "value": "GET", "required": nil},
+			Button{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-blue-800"),
+				Attrs{"type": "submit"}, "Test"},
+		},
+		P{Id("policy-test"), Class("pt-2")},
+
+		H2{Class("text-xl pt-8 pb-2"), "Recent changes"},
+		policyChanges(changes),
+	})
+	return nil
+}
+
+// GetStatus is the version of the policy this machine has loaded, and how
+// its last reload went.
+func (h *AdminPolicies) GetStatus(w http.ResponseWriter, r *http.Request) {
+	var status policies.WatcherStatus
+	if h.watcher != nil {
+		status = h.watcher.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *AdminPolicies) POST(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAdminPolicies")
+
+	p, err := h.policyForm(r, "")
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "A policy needs a subject rule, an object and an action.", err)
+	}
+
+	actor := access.Actor(ctx)
+	if err = h.policies.Add(ctx, actor, p); errors.Is(err, policies.ErrInvalid) {
+		return mux.NewError(http.StatusUnprocessableEntity, err.Error(), err)
+	} else if errors.Is(err, policies.ErrExists) {
+		return mux.NewError(http.StatusConflict, "That policy already exists.", err)
+	} else if err != nil {
+		return fmt.Errorf("adding policy: %w", err)
+	}
+
+	logger.Info("Policy added", "actor", actor, "policy", p)
+	h.changed(w, r, policyRow(p))
+	return nil
+}
+
+func (h *AdminPolicies) PUT(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PutAdminPolicies")
+
+	old, err := h.policyForm(r, "old_")
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "The policy being changed is invalid, reload the page.", err)
+	}
+	p, err := h.policyForm(r, "")
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "A policy needs a subject rule, an object and an action.", err)
+	}
+
+	if old == p {
+		h.changed(w, r, policyRow(p))
+		return nil
+	}
+
+	actor := access.Actor(ctx)
+	if err = h.policies.Update(ctx, actor, old, p); errors.Is(err, policies.ErrInvalid) {
+		return mux.NewError(http.StatusUnprocessableEntity, err.Error(), err)
+	} else if errors.Is(err, policies.ErrExists) {
+		return mux.NewError(http.StatusConflict, "That policy already exists.", err)
+	} else if errors.Is(err, policies.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "That policy no longer exists, reload the page.", err)
+	} else if err != nil {
+		return fmt.Errorf("updating policy: %w", err)
+	}
+
+	logger.Info("Policy updated", "actor", actor, "old", old, "policy", p)
+	h.changed(w, r, policyRow(p))
+	return nil
+}
+
+func (h *AdminPolicies) DELETE(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAdminPolicies")
+
+	// the row's current values are sent as old_*, edits that weren't saved
+	// don't matter.
+	p, err := h.policyForm(r, "old_")
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "The policy being changed is invalid, reload the page.", err)
+	}
+
+	actor := access.Actor(ctx)
+	if err = h.policies.Remove(ctx, actor, p); errors.Is(err, policies.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "That policy no longer exists, reload the page.", err)
+	} else if err != nil {
+		return fmt.Errorf("removing policy: %w", err)
+	}
+
+	logger.Info("Policy removed", "actor", actor, "policy", p)
+	h.changed(w, r)
+	return nil
+}
+
+func (h *AdminPolicies) PostRoles(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAdminPolicyRoles")
+
+	a, err := h.roleForm(r)
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "A role needs a name, and someone to be given to.", err)
+	}
+
+	actor := access.Actor(ctx)
+	event := access.AuditEvent(ctx, model.AuditAssignRole, a.Role+" to "+a.Subject, model.AuditSuccess)
+	if err = h.policies.Assign(ctx, actor, a); errors.Is(err, policies.ErrInvalidRole) {
+		return mux.NewError(http.StatusUnprocessableEntity, err.Error(), err)
+	} else if errors.Is(err, policies.ErrExists) {
+		return mux.NewError(http.StatusConflict, "That role is already assigned.", err)
+	} else if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("assigning role: %w", err)
+	}
+	recordAudit(ctx, h.audit, event)
+
+	logger.Info("Role assigned", "actor", actor, "role", a.Role, "subject", a.Subject)
+	h.changed(w, r, roleRow(a), P{Id("role-error"), Class("text-red-400 pb-2"), Attrs{"hx-swap-oob": true}})
+	return nil
+}
+
+func (h *AdminPolicies) DeleteRoles(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAdminPolicyRoles")
+
+	a, err := h.roleForm(r)
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "The role being unassigned is invalid, reload the page.", err)
+	}
+
+	actor := access.Actor(ctx)
+	event := access.AuditEvent(ctx, model.AuditUnassignRole, a.Role+" from "+a.Subject, model.AuditSuccess)
+	if err = h.policies.Unassign(ctx, actor, a); errors.Is(err, policies.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "That role is no longer assigned, reload the page.", err)
+	} else if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("unassigning role: %w", err)
+	}
+	recordAudit(ctx, h.audit, event)
+
+	logger.Info("Role unassigned", "actor", actor, "role", a.Role, "subject", a.Subject)
+	h.changed(w, r, P{Id("role-error"), Class("text-red-400 pb-2"), Attrs{"hx-swap-oob": true}})
+	return nil
+}
+
+func (h *AdminPolicies) PostTest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	q := struct {
+		Sub model.Subject
+		Obj string `validate:"required"`
+		Act string `validate:"required"`
+	}{model.Subject{Provider: r.FormValue("provider"), User: r.FormValue("user"),
+		Groups: strings.Fields(r.FormValue("groups"))},
+		r.FormValue("obj"), r.FormValue("act")}
+	if err := h.validate.Struct(q); err != nil {
+		return mux.NewError(http.StatusBadRequest, "A test needs an object and an action.", err)
+	}
+
+	ok, p, err := h.policies.Test(q.Sub, q.Obj, q.Act)
+	if err != nil {
+		return mux.NewError(http.StatusInternalServerError, err.Error(), err)
+	}
+
+	RenderContext(w, ctx, Span{
+		If(ok, Span{Class("text-green-400"), "Allowed"}).Else(Span{Class("text-red-400"), "Denied"}),
+		If(p != nil, func() any {
+			return Fragment{" by ", Code{p.SubRule, ", ", p.Obj, ", ", p.Act}}
+		}),
+	})
+	return nil
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	. "github.com/Gardego5/htmdsl"
+	. "github.com/Gardego5/htmdsl/util"
+	"github.com/elliotchance/pie/v2"
+)
+
+type AdminSessions struct {
+	sessions *session.Service
+}
+
+func NewAdminSessions(
+	sessions *session.Service,
+) *AdminSessions {
+	return &AdminSessions{sessions: sessions}
+}
+
+func (h *AdminSessions) GET(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	sessions, err := h.sessions.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+
+	current := access.Session(ctx)
+
+	render.Page(w, r, nil, components.Header{Title: "Sessions"}, components.Margins{
+		Ul{Class("grid grid-cols-1 gap-6"),
+			Attrs{
+				"hx-target": "closest li",
+				"hx-swap":   "outerHTML swap:0.1s",
+			},
+			pie.Map(sessions, func(s model.Session) any {
+				return Li{Class("relative rounded-sm border border-slate-500 bg-gray-800 p-4",
+					"[&.htmx-swapping]:transition-opacity [&.htmx-swapping]:opacity-0 list-none",
+				),
+					Div{Class("flex justify-between gap-2 mb-2"),
+						Span{Class("flex-grow"),
+							If(s.User != "", s.User).Else(Span{Class("text-gray-400"), "anonymous"}),
+							If(s.ID == current, Span{Class("text-gray-400"), " (this session)"}),
+						},
+						Code{Class("text-gray-400"), s.IP},
+					},
+					P{Class("text-sm text-gray-400 truncate"), s.UserAgent},
+
+					Div{Class("absolute -bottom-[7px] right-8 flex gap-2"),
+						P{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-xs grid place-items-center"),
+							"seen ", s.LastSeen.Format(time.RFC1123Z),
+						},
+
+						Button{
+							Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-red-800 grid place-items-center"),
+							Attrs{
+								"hx-delete":  fmt.Sprintf("/admin/sessions/%s", s.ID),
+								"hx-confirm": "Revoke this session?",
+							},
+							Element("iconify-icon", Attrs{"icon": "mdi:logout", "width": 20, "height": 20}),
+						},
+					},
+				}
+			}),
+		},
+	})
+	return nil
+}
+
+func (h *AdminSessions) DELETE(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAdminSession")
+
+	id := r.PathValue("id")
+	if err := h.sessions.Revoke(ctx, id); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+
+	logger.Info("Session revoked", "session", id)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
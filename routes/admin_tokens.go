@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/components"
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/tokens"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	. "github.com/Gardego5/htmdsl"
+	. "github.com/Gardego5/htmdsl/util"
+	"github.com/elliotchance/pie/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+type AdminTokens struct {
+	tokens   *tokens.Service
+	audit    *audit.Service
+	validate *validator.Validate
+}
+
+func NewAdminTokens(
+	tokens *tokens.Service,
+	audit *audit.Service,
+	validate *validator.Validate,
+) *AdminTokens {
+	return &AdminTokens{tokens: tokens, audit: audit, validate: validate}
+}
+
+// tokenExpiries are the lifetimes tokens can be made with, 0 never expires.
+var tokenExpiries = []struct {
+	Days  int
+	Title string
+}{{30, "30 days"}, {90, "90 days"}, {365, "1 year"}, {0, "never"}}
+
+func tokenRow(t model.APIToken) any {
+	format := func(t model.NullTime, otherwise string) any {
+		return If(t.Valid, func() any { return t.Time.Time.Format(time.DateOnly) }).Else(otherwise)
+	}
+
+	return Li{Class("relative rounded-sm border border-slate-500 bg-gray-800 p-4",
+		"[&.htmx-swapping]:transition-opacity [&.htmx-swapping]:opacity-0 list-none",
+	),
+		Div{Class("flex justify-between gap-2 mb-2"),
+			Span{Class("flex-grow"), t.Name},
+			Code{Class("text-gray-400"), t.Prefix, "…"},
+		},
+		P{Class("text-sm text-gray-400"),
+			"scopes ", Code{pie.Join(t.Scopes, " ")},
+			", expires ", format(t.ExpiresAt, "never"),
+			", last used ", format(t.LastUsedAt, "never"),
+		},
+
+		Div{Class("absolute -bottom-[7px] right-8 flex gap-2"),
+			P{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-xs grid place-items-center"),
+				"created ", t.CreatedAt.Time.Format(time.RFC1123Z),
+			},
+
+			Button{
+				Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-red-800 grid place-items-center"),
+				Attrs{
+					"hx-delete":  fmt.Sprintf("/admin/tokens/%d", t.ID),
+					"hx-confirm": "Revoke this token? Anything using it will stop working.",
+				},
+				Element("iconify-icon", Attrs{"icon": "mdi:key-remove", "width": 20, "height": 20}),
+			},
+		},
+	}
+}
+
+// fromToken refuses requests authorized by an api token, so a leaked token
+// can't be used to make more of them.
+func (h *AdminTokens) fromToken(r *http.Request) error {
+	if access.APIToken(r.Context()) == nil {
+		return nil
+	}
+	return mux.NewError(http.StatusForbidden, "API tokens can't manage API tokens.", nil)
+}
+
+func (h *AdminTokens) GET(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if err := h.fromToken(r); err != nil {
+		return err
+	}
+
+	list, err := h.tokens.List(ctx, access.Actor(ctx))
+	if err != nil {
+		return fmt.Errorf("listing tokens: %w", err)
+	}
+
+	render.Page(w, r, nil, components.Header{Title: "Tokens"}, components.Margins{
+		P{Class("text-gray-400 text-sm pb-4"),
+			"Tokens act as you, limited to their scopes, when they're sent as ",
+			Code{"Authorization: Bearer <token>"}, ". ",
+			Code{"read"}, " allows GET requests, ", Code{"write"}, " allows any request.",
+		},
+
+		components.Form{Class("flex flex-wrap items-end gap-4 pb-4"),
+			Attrs{
+				"hx-post":              "/admin/tokens",
+				"hx-target":            "#token-created",
+				"hx-target-error":      "#token-created",
+				"hx-on::after-request": "if (event.detail.successful) this.reset()",
+			},
+			Label{Class("grid"), "Name",
+				Input{"class": adminInputClass, "name": "name", "placeholder": "ci", "required": nil}},
+			Fieldset{Class("flex gap-4"),
+				pie.Map(model.TokenScopes, func(scope model.TokenScope) any {
+					return Label{Class("flex gap-1 items-center"),
+						Input{"type": "checkbox", "name": "scopes", "value": string(scope),
+							"checked": AttrIf(scope == model.TokenScopeRead)},
+						string(scope)}
+				}),
+			},
+			Label{Class("grid"), "Expires",
+				Select{Class(adminInputClass), Attrs{"name": "expires"},
+					pie.Map(tokenExpiries, func(e struct {
+						Days  int
+						Title string
+					}) any {
+						return Option{Attrs{"value": e.Days, "selected": AttrIf(e.Days == 90)}, e.Title}
+					}),
+				}},
+			Button{Class("rounded-sm border border-slate-500 bg-zinc-900 px-4 py-1 text-sm hover:bg-green-800"),
+				Attrs{"type": "submit"}, "Create"},
+		},
+		Div{Id("token-created"), Class("pb-4")},
+
+		Ul{Id("tokens"), Class("grid grid-cols-1 gap-6"),
+			Attrs{
+				"hx-target": "closest li",
+				"hx-swap":   "outerHTML swap:0.1s",
+			},
+			pie.Map(list, tokenRow),
+		},
+	})
+	return nil
+}
+
+func (h *AdminTokens) POST(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAdminTokens")
+	if err := h.fromToken(r); err != nil {
+		return err
+	}
+
+	r.ParseForm()
+	q := struct {
+		Name   string       `validate:"required,max=100"`
+		Scopes model.Scopes `validate:"min=1,dive,oneof=read write"`
+		Days   int          `validate:"min=0,max=365"`
+	}{Name: r.FormValue("name")}
+	q.Days, _ = strconv.Atoi(r.FormValue("expires"))
+	for _, scope := range r.Form["scopes"] {
+		q.Scopes = append(q.Scopes, model.TokenScope(scope))
+	}
+	if err := h.validate.Struct(q); err != nil {
+		return mux.NewError(http.StatusBadRequest, "A token needs a name, and at least one scope.", err)
+	}
+
+	var expires time.Time
+	if q.Days > 0 {
+		expires = time.Now().AddDate(0, 0, q.Days)
+	}
+
+	event := access.AuditEvent(ctx, model.AuditCreateToken, q.Name, model.AuditSuccess)
+	secret, token, err := h.tokens.Create(ctx, *access.Subject(ctx), q.Name, q.Scopes, expires)
+	if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("creating token: %w", err)
+	}
+	event.Target = fmt.Sprintf("%s (%d)", token.Name, token.ID)
+	recordAudit(ctx, h.audit, event)
+
+	logger.Info("Token created", "token", token.ID, "scopes", token.Scopes)
+	RenderContext(w, ctx, Fragment{
+		Div{Class("rounded-sm border border-green-700 bg-gray-800 p-4 grid gap-2"),
+			P{"Copy the token now, it won't be shown again."},
+			Pre{Class("bg-zinc-900 rounded-sm border border-slate-500 px-2 py-1 select-all break-all whitespace-pre-wrap"),
+				secret},
+		},
+		Div{Attrs{"hx-swap-oob": "afterbegin:#tokens"}, tokenRow(*token)},
+	})
+	return nil
+}
+
+func (h *AdminTokens) DELETE(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAdminToken")
+	if err := h.fromToken(r); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "The id must be a number.", err)
+	}
+
+	event := access.AuditEvent(ctx, model.AuditRevokeToken, strconv.Itoa(id), model.AuditSuccess)
+	if err = h.tokens.Revoke(ctx, access.Actor(ctx), id); errors.Is(err, tokens.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "There's no token with that id.", err)
+	} else if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("revoking token: %w", err)
+	}
+	recordAudit(ctx, h.audit, event)
+
+	logger.Info("Token revoked", "id", id)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
@@ -0,0 +1,112 @@
+package routes_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/initialize"
+	"github.com/Gardego5/garrettdavis.dev/resource/middleware"
+	. "github.com/Gardego5/garrettdavis.dev/routes"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/service/policies"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/service/tokens"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+	"github.com/Gardego5/garrettdavis.dev/utils/testdb"
+	"github.com/casbin/casbin/v2"
+	casbinmodel "github.com/casbin/casbin/v2/model"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/go-github/v66/github"
+)
+
+func TestAdminTokensFromToken(t *testing.T) {
+	ctx := context.Background()
+	db := testdb.Open(t)
+	apiTokens, err := tokens.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audits, err := audit.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := casbinmodel.NewModelFromString(initialize.ModelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction("hasRole", policies.HasRole(enforcer))
+	for _, act := range []string{"GET", "POST", "DELETE"} {
+		enforcer.AddPolicy(`hasRole(r.sub, "admin")`, "/admin/*", act)
+	}
+	enforcer.AddGroupingPolicy("Gardego5", policies.RolePrefix+"admin")
+
+	caches := bimarshal.Caches{
+		"user":          bimarshal.Register[github.User](bimarshal.JSON),
+		"github-groups": bimarshal.Register[model.GithubGroups](bimarshal.MessagePack),
+		"access-token":  bimarshal.Register[model.AccessToken](bimarshal.MessagePack),
+		"session":       bimarshal.Register[model.Session](bimarshal.MessagePack),
+		"subject":       bimarshal.Register[model.Subject](bimarshal.MessagePack),
+	}.Build(bimarshal.NewMemoryStore(100))
+	authorization := middleware.Authorization(slog.Default(), enforcer, session.New(caches, time.Hour),
+		currentuser.New(caches), apiTokens, audits, "https://garrettdavis.dev")
+
+	keys, err := symetric.NewKeyring("a very long application secret used for testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAdminTokens(apiTokens, audits, validator.New())
+	routes := http.NewServeMux()
+	routes.Handle("GET /admin/tokens", authorization.Use(mux.HandlerFunc(h.GET)))
+	routes.Handle("POST /admin/tokens", authorization.Use(mux.HandlerFunc(h.POST)))
+	routes.Handle("DELETE /admin/tokens/{id}", authorization.Use(mux.HandlerFunc(h.DELETE)))
+	server := httptest.NewServer(middleware.LoggerAndSessions(slog.Default(), 0, cookie.NewSession(keys)).Use(routes))
+	t.Cleanup(server.Close)
+
+	admin := model.Subject{Provider: model.ProviderGithub, User: "Gardego5"}
+	secret, token, err := apiTokens.Create(ctx, admin, "write", model.Scopes{model.TokenScopeWrite}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"name": {"another"}, "scopes": {"write"}, "expires": {"0"}}.Encode()
+	for _, test := range []struct{ method, path, body string }{
+		{"GET", "/admin/tokens", ""},
+		{"POST", "/admin/tokens", form},
+		{"DELETE", "/admin/tokens/" + strconv.Itoa(token.ID), ""},
+	} {
+		req, _ := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s: expected tokens not to manage tokens, got %d", test.method, test.path, res.StatusCode)
+		}
+	}
+
+	// nothing was made or revoked.
+	if list, err := apiTokens.List(ctx, admin.User); err != nil || len(list) != 1 || list[0].ID != token.ID {
+		t.Errorf("expected only the original token, got %v, %v", list, err)
+	}
+}
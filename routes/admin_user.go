@@ -2,6 +2,8 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/Gardego5/garrettdavis.dev/components"
@@ -21,18 +23,16 @@ func NewAdminUser(
 	return &AdminUser{currentuser: currentuser}
 }
 
-func (h *AdminUser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (h *AdminUser) GET(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	logger := access.Logger(ctx, "GetAdminUser")
 	session := access.Session(ctx)
 
-	user, err := h.currentuser.GetUserBySession(ctx, session)
-	if err != nil {
-		logger.Error("Error getting user", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		render.Page(w, r, nil,
-			components.Header{},
-			components.Margins(P{"An error has occurred."}))
+	// only github has more to say about the user than the session does.
+	var user any = access.SessionData(ctx)
+	if u, err := h.currentuser.GetUserBySession(ctx, session); err == nil {
+		user = u
+	} else if !errors.Is(err, currentuser.ErrNotGithub) {
+		return fmt.Errorf("getting user: %w", err)
 	}
 
 	data, _ := json.MarshalIndent(user, "", "  ")
@@ -43,4 +43,5 @@ func (h *AdminUser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				string(data),
 			}},
 		))
+	return nil
 }
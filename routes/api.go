@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/Gardego5/garrettdavis.dev/utils/openapi"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	// apiItem is the envelope a single resource is sent in.
+	apiItem[T any] struct {
+		Data T `json:"data"`
+	}
+	// apiList is the envelope a list of resources is sent in.
+	apiList[T any] struct {
+		Data []T         `json:"data"`
+		Meta apiListMeta `json:"meta"`
+	}
+	apiListMeta struct {
+		Total  int `json:"total"`
+		Limit  int `json:"limit,omitempty"`
+		Offset int `json:"offset"`
+	}
+)
+
+// newAPIList wraps data, which is sent as an empty list rather than null when
+// there's nothing in it.
+func newAPIList[T any](data []T, meta apiListMeta) apiList[T] {
+	if data == nil {
+		data = []T{}
+	}
+	return apiList[T]{Data: data, Meta: meta}
+}
+
+// APINotFound answers requests to the api that don't match any route.
+func APINotFound(w http.ResponseWriter, r *http.Request) error {
+	return mux.NewError(http.StatusNotFound, fmt.Sprintf("There's nothing at %s.", r.URL.Path), nil)
+}
+
+// apiInvalid is the error of an invalid v, describing what's wrong with each
+// of its invalid fields, by the name it has in tag.
+func apiInvalid(err error, v any, tag string) error {
+	fields := map[string]string{}
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		t := reflect.TypeOf(v)
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		for _, e := range errs {
+			name := e.StructField()
+			if field, ok := t.FieldByName(e.StructField()); ok {
+				if tagged, _, _ := strings.Cut(field.Tag.Get(tag), ","); tagged != "" {
+					name = tagged
+				}
+			}
+			fields[name] = fmt.Sprintf("failed the %q check.", e.Tag())
+		}
+	}
+
+	return &mux.Error{
+		Status:  http.StatusBadRequest,
+		Message: "The request is invalid.",
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// apiDecode reads the json body of r into v.
+func apiDecode(w http.ResponseWriter, r *http.Request, v any) error {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return mux.NewError(http.StatusUnsupportedMediaType, "The body must be application/json.", nil)
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return mux.NewError(http.StatusBadRequest, "The body isn't valid json: "+err.Error(), err)
+	}
+	return nil
+}
+
+type APIDocs struct {
+	document []byte
+}
+
+// NewAPIDocs generates the OpenAPI document of the api.
+func NewAPIDocs(baseUrl string) (*APIDocs, error) {
+	document, err := json.Marshal(apiDocument(baseUrl))
+	if err != nil {
+		return nil, err
+	}
+	return &APIDocs{document: document}, nil
+}
+
+func (h *APIDocs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(h.document)
+}
+
+// apiDocument describes every route of the api, which has to be kept in sync
+// with how they're registered. The schemas are generated from the types the
+// handlers use.
+func apiDocument(baseUrl string) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "garrettdavis.dev",
+		Version: "1",
+		Description: "Routes that need authorization take a personal api token, " +
+			"created at /admin/tokens, as a bearer token.",
+	}, openapi.Server{URL: baseUrl + "/api/v1"})
+	doc.Name = func(t reflect.Type) string {
+		name := strings.TrimPrefix(t.Name(), "api")
+		return strings.ToUpper(name[:1]) + name[1:]
+	}
+	doc.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"token": {Type: "http", Scheme: "bearer"},
+	}
+	token := []map[string][]string{{"token": {}}}
+
+	// responses are the responses of an operation that succeeds with status,
+	// sending v unless it's nil, or fails with any of errs.
+	responses := func(status int, description string, v any, errs ...int) map[string]openapi.Response {
+		success := openapi.Response{Description: description}
+		if v != nil {
+			success.Content = doc.JSON(v)
+		}
+		responses := map[string]openapi.Response{fmt.Sprint(status): success}
+		for _, err := range append(errs, http.StatusNotAcceptable, http.StatusInternalServerError) {
+			responses[fmt.Sprint(err)] = openapi.Response{
+				Description: http.StatusText(err), Content: doc.JSON(render.ErrorResponse{})}
+		}
+		return responses
+	}
+	path := func(name string, v any) []openapi.Parameter {
+		return []openapi.Parameter{{Name: name, In: "path", Required: true, Schema: doc.Schema(v)}}
+	}
+
+	doc.Add("GET", "/messages", &openapi.Operation{
+		OperationID: "listMessages", Summary: "List contact messages", Tags: []string{"messages"},
+		Parameters: doc.Parameters(apiMessagesQuery{}, "q"),
+		Responses: responses(http.StatusOK, "The messages", apiList[apiMessage]{},
+			http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden),
+		Security: token,
+	})
+	doc.Add("POST", "/messages", &openapi.Operation{
+		OperationID: "createMessage", Summary: "Send a contact message", Tags: []string{"messages"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: doc.JSON(apiMessageInput{})},
+		Responses: responses(http.StatusCreated, "The message", apiItem[apiMessage]{},
+			http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusTooManyRequests),
+	})
+	doc.Add("DELETE", "/messages/{id}", &openapi.Operation{
+		OperationID: "deleteMessage", Summary: "Delete a contact message", Tags: []string{"messages"},
+		Parameters: path("id", 0),
+		Responses: responses(http.StatusNoContent, "The message was deleted", nil,
+			http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+		Security: token,
+	})
+
+	doc.Add("GET", "/posts", &openapi.Operation{
+		OperationID: "listPosts", Summary: "List published blog posts", Tags: []string{"posts"},
+		Responses: responses(http.StatusOK, "The posts, newest first", apiList[apiPost]{}),
+	})
+	doc.Add("GET", "/posts/{slug}", &openapi.Operation{
+		OperationID: "getPost", Summary: "Get a blog post, with its content", Tags: []string{"posts"},
+		Parameters: path("slug", ""),
+		Responses:  responses(http.StatusOK, "The post", apiItem[apiPostDetail]{}, http.StatusNotFound),
+	})
+
+	doc.Add("GET", "/presentations", &openapi.Operation{
+		OperationID: "listPresentations", Summary: "List published presentations", Tags: []string{"presentations"},
+		Responses: responses(http.StatusOK, "The presentations", apiList[apiPresentation]{}),
+	})
+	doc.Add("GET", "/presentations/{slug}", &openapi.Operation{
+		OperationID: "getPresentation", Summary: "Get a presentation, with its slides", Tags: []string{"presentations"},
+		Parameters: path("slug", ""),
+		Responses:  responses(http.StatusOK, "The presentation", apiItem[apiPresentationDetail]{}, http.StatusNotFound),
+	})
+
+	doc.Add("GET", "/openapi.json", &openapi.Operation{
+		OperationID: "getOpenAPI", Summary: "This document", Tags: []string{"docs"},
+		Responses: responses(http.StatusOK, "The OpenAPI document of the api", nil),
+	})
+
+	return doc
+}
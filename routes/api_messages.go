@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/messages"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/elliotchance/pie/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/monoculum/formam"
+)
+
+type (
+	apiMessage struct {
+		ID        int       `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Message   string    `json:"message"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	apiMessageInput struct {
+		Name    string `json:"name"    validate:"required,max=200"`
+		Email   string `json:"email"   validate:"required,email"`
+		Message string `json:"message" validate:"required,max=10000"`
+	}
+	apiMessagesQuery struct {
+		Sort   messages.ListMessageInputSort `q:"sort"   validate:"oneof=ASC DESC"`
+		Limit  int                           `q:"limit"  validate:"min=1,max=100"`
+		Offset int                           `q:"offset" validate:"min=0"`
+	}
+)
+
+func newAPIMessage(msg model.ContactMessage) apiMessage {
+	return apiMessage{
+		ID:        msg.ID,
+		Name:      msg.Name,
+		Email:     msg.Email,
+		Message:   msg.Message,
+		CreatedAt: msg.CreatedAt.Time,
+	}
+}
+
+type APIMessages struct {
+	messages *messages.Service
+	audit    *audit.Service
+}
+
+func NewAPIMessages(
+	messages *messages.Service,
+	audit *audit.Service,
+) *APIMessages {
+	return &APIMessages{messages: messages, audit: audit}
+}
+
+func (h *APIMessages) GET(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	q := apiMessagesQuery{messages.ListMessageInputSortDESC, 20, 0}
+	r.ParseForm()
+	access.Get[formam.Decoder](ctx).Decode(r.Form, &q)
+	if err := access.Get[validator.Validate](ctx).Struct(q); err != nil {
+		return apiInvalid(err, q, "q")
+	}
+
+	msgs, err := h.messages.ListMessages(ctx, &messages.ListMessageInput{
+		Sort: q.Sort, Limit: q.Limit, Offset: q.Offset})
+	if err != nil {
+		return fmt.Errorf("listing messages: %w", err)
+	}
+
+	count, err := h.messages.CountMessages(ctx)
+	if err != nil {
+		return fmt.Errorf("counting messages: %w", err)
+	}
+
+	render.JSON(w, http.StatusOK, newAPIList(pie.Map(msgs, newAPIMessage),
+		apiListMeta{Total: count, Limit: q.Limit, Offset: q.Offset}))
+	return nil
+}
+
+func (h *APIMessages) POST(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAPIMessages")
+
+	var input apiMessageInput
+	if err := apiDecode(w, r, &input); err != nil {
+		return err
+	}
+	if err := access.Get[validator.Validate](ctx).Struct(input); err != nil {
+		return apiInvalid(err, input, "json")
+	}
+
+	// escaped like messages from the contact page, so they're all the same.
+	msg := model.ContactMessage{
+		Name:      html.EscapeString(input.Name),
+		Email:     input.Email,
+		Message:   html.EscapeString(input.Message),
+		CreatedAt: model.Time{Time: time.Now()},
+	}
+	if err := h.messages.CreateMessage(ctx, &msg); err != nil {
+		return fmt.Errorf("creating message: %w", err)
+	}
+
+	logger.Info("Message created", "id", msg.ID)
+	render.JSON(w, http.StatusCreated, apiItem[apiMessage]{Data: newAPIMessage(msg)})
+	return nil
+}
+
+func (h *APIMessages) DELETE(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "DeleteAPIMessage")
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return mux.NewError(http.StatusBadRequest, "The id must be a number.", err)
+	}
+
+	event := access.AuditEvent(ctx, model.AuditDeleteMessage, strconv.Itoa(id), model.AuditSuccess)
+	if err = h.messages.DeleteMessage(ctx, &messages.DeleteMessageInput{ID: id}); errors.Is(err, messages.ErrNotFound) {
+		return mux.NewError(http.StatusNotFound, "There's no message with that id.", err)
+	} else if err != nil {
+		event.Result = model.AuditFailure
+		recordAudit(ctx, h.audit, event)
+		return fmt.Errorf("deleting message: %w", err)
+	}
+
+	recordAudit(ctx, h.audit, event)
+	logger.Info("Message deleted", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/blog"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/elliotchance/pie/v2"
+)
+
+type (
+	apiPost struct {
+		Slug        string    `json:"slug"`
+		Title       string    `json:"title"`
+		Author      string    `json:"author"`
+		Description string    `json:"description"`
+		Live        bool      `json:"live"`
+		CreatedAt   time.Time `json:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at"`
+		// URL is the page of the post.
+		URL string `json:"url"`
+	}
+	apiPostDetail struct {
+		apiPost
+		// Content is the html of the post, which is styled by CSS.
+		Content string `json:"content"`
+		CSS     string `json:"css"`
+	}
+)
+
+func newAPIPost(post blog.Post) apiPost {
+	return apiPost{
+		Slug:        post.Name,
+		Title:       post.Title,
+		Author:      post.Author,
+		Description: post.Description,
+		Live:        post.Live,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdateAt,
+		URL:         "/blog/" + post.Name,
+	}
+}
+
+type APIPosts struct {
+	blog *blog.Service
+}
+
+func NewAPIPosts(
+	blog *blog.Service,
+) *APIPosts {
+	return &APIPosts{blog: blog}
+}
+
+// GET lists the posts that are live, like the index page does.
+func (h *APIPosts) GET(w http.ResponseWriter, r *http.Request) {
+	posts := h.blog.Live()
+	render.JSON(w, http.StatusOK, newAPIList(pie.Map(posts, newAPIPost), apiListMeta{Total: len(posts)}))
+}
+
+func (h *APIPosts) GetPost(w http.ResponseWriter, r *http.Request) error {
+	post, found := h.blog.Posts()[r.PathValue("slug")]
+	if !found {
+		return mux.NewError(http.StatusNotFound, "There's no post with that slug.", nil)
+	}
+
+	render.JSON(w, http.StatusOK, apiItem[apiPostDetail]{Data: apiPostDetail{
+		apiPost: newAPIPost(post),
+		Content: post.Content,
+		CSS:     post.Css,
+	}})
+	return nil
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/presentations"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
+	"github.com/elliotchance/pie/v2"
+)
+
+type (
+	apiPresentation struct {
+		Slug       string `json:"slug"`
+		Live       bool   `json:"live"`
+		SlideCount int    `json:"slide_count"`
+		// URL is the page of the presentation.
+		URL string `json:"url"`
+	}
+	apiPresentationDetail struct {
+		apiPresentation
+		// Content is the html before the first slide, which like the slides
+		// is styled by CSS.
+		Content string     `json:"content"`
+		CSS     string     `json:"css"`
+		Slides  []apiSlide `json:"slides"`
+	}
+	apiSlide struct {
+		Class   string `json:"class"`
+		Content string `json:"content"`
+	}
+)
+
+func newAPIPresentation(pres presentations.Presentation) apiPresentation {
+	return apiPresentation{
+		Slug:       pres.Name,
+		Live:       pres.Live,
+		SlideCount: len(pres.Slides),
+		URL:        "/presentations/" + pres.Name,
+	}
+}
+
+type APIPresentations struct {
+	presentations *presentations.Service
+}
+
+func NewAPIPresentations(
+	presentations *presentations.Service,
+) *APIPresentations {
+	return &APIPresentations{presentations: presentations}
+}
+
+// GET lists the presentations that are live, by slug.
+func (h *APIPresentations) GET(w http.ResponseWriter, r *http.Request) {
+	list := pie.Filter(pie.Values(h.presentations.Presentations()), func(pres presentations.Presentation) bool {
+		return pres.Live
+	})
+	slices.SortFunc(list, func(a, z presentations.Presentation) int { return strings.Compare(a.Name, z.Name) })
+
+	render.JSON(w, http.StatusOK, newAPIList(pie.Map(list, newAPIPresentation), apiListMeta{Total: len(list)}))
+}
+
+func (h *APIPresentations) GetPresentation(w http.ResponseWriter, r *http.Request) error {
+	pres, found := h.presentations.Presentations()[r.PathValue("slug")]
+	if !found {
+		return mux.NewError(http.StatusNotFound, "There's no presentation with that slug.", nil)
+	}
+
+	render.JSON(w, http.StatusOK, apiItem[apiPresentationDetail]{Data: apiPresentationDetail{
+		apiPresentation: newAPIPresentation(pres),
+		Content:         string(pres.Content),
+		CSS:             string(pres.Css),
+		Slides: pie.Map(pres.Slides, func(slide presentations.Slide) apiSlide {
+			return apiSlide{Class: slide.Class, Content: string(slide.Content)}
+		}),
+	}})
+	return nil
+}
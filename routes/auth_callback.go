@@ -1,207 +1,157 @@
 package routes
 
 import (
-	"crypto/cipher"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
-	"time"
 
 	"github.com/Gardego5/garrettdavis.dev/components"
 	"github.com/Gardego5/garrettdavis.dev/model"
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
-	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
-	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/auth"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils"
 	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
-	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
 	. "github.com/Gardego5/htmdsl"
 	"github.com/casbin/casbin/v2"
 	"github.com/go-playground/validator/v10"
 )
 
 type AuthCallback struct {
-	clientId, clientSecret string
-	validate               *validator.Validate
-	block                  cipher.Block
-	currentuser            *currentuser.Service
-	subjects               bimarshal.Cache[model.Subject]
-	accessTokens           bimarshal.Cache[model.GHAccessToken]
-	enforcer               *casbin.Enforcer
-	baseUrl                string
+	validate      *validator.Validate
+	auth          *auth.Service
+	stateCookie   *cookie.Codec[cookie.StateValue]
+	sessionCookie *cookie.Codec[cookie.SessionValue]
+	sessions      *session.Service
+	enforcer      *casbin.SyncedEnforcer
+	audit         *audit.Service
+	baseUrl       string
 }
 
 func NewAuthCallback(
-	clientId, clientSecret string,
 	validator *validator.Validate,
-	block cipher.Block,
-	currentuser *currentuser.Service,
-	caches bimarshal.RegisteredCaches,
-	enforcer *casbin.Enforcer,
+	auth *auth.Service,
+	stateCookie *cookie.Codec[cookie.StateValue],
+	sessionCookie *cookie.Codec[cookie.SessionValue],
+	sessions *session.Service,
+	enforcer *casbin.SyncedEnforcer,
+	audit *audit.Service,
 	baseUrl string,
 ) *AuthCallback {
 	return &AuthCallback{
-		clientId:     clientId,
-		clientSecret: clientSecret,
-		validate:     validator,
-		block:        block,
-		currentuser:  currentuser,
-		subjects:     bimarshal.Get[model.Subject](caches),
-		accessTokens: bimarshal.Get[model.GHAccessToken](caches),
-		enforcer:     enforcer,
-		baseUrl:      baseUrl,
+		validate:      validator,
+		auth:          auth,
+		stateCookie:   stateCookie,
+		sessionCookie: sessionCookie,
+		sessions:      sessions,
+		enforcer:      enforcer,
+		audit:         audit,
+		baseUrl:       baseUrl,
 	}
 }
 
-func (h *AuthCallback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (h *AuthCallback) GET(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
-	logger := access.Logger(ctx, "PostAuthCallback")
+	logger := access.Logger(ctx, "GetAuthCallback").With("provider", r.PathValue("provider"))
+
+	// every attempt to sign in is audited, as whoever it ended up being.
+	var sub *model.Subject
+	result := model.AuditFailure
+	defer func() {
+		event := access.AuditEvent(ctx, model.AuditSignIn, r.PathValue("provider"), result)
+		if sub != nil {
+			event.Actor = sub.User
+		}
+		recordAudit(ctx, h.audit, event)
+	}()
 
 	// delete the cookie regardless of the outcome
-	cookie.Delete(w, cookie.State)
-
-	// read the state cookie
-	c, err := r.Cookie(cookie.State.Name)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		logger.Warn("Error reading state cookie", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
+	h.stateCookie.Delete(w)
 
-	// decrypt the state
-	state, err := symetric.Decrypt(h.block, c.Value)
+	// read and decrypt the state cookie
+	attempt, err := h.stateCookie.Get(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		logger.Warn("Error decrypting state cookie", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+		return signInFailed(fmt.Errorf("reading state cookie: %w", err))
 	}
 
-	// parse the query parameters we are given from github
+	// parse the query parameters we are given from the provider
 	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return signInFailed(fmt.Errorf("provider returned %s: %s", e, q.Get("error_description")))
+	}
 	payload := struct {
 		Code  string `validate:"required"`
 		State string `validate:"required"`
 	}{q.Get("code"), q.Get("state")}
 	if err = h.validate.Struct(payload); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		logger.Warn("Error validating payload", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
-
-	// compare the state we received from github to the state we sent to github
-	if state != payload.State {
-		w.WriteHeader(http.StatusUnauthorized)
-		logger.Warn("State mismatch", "expected", state, "received", payload.State)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
-
-	// send a request to github to get the access token
-	q = url.Values{}
-	q.Set("client_id", h.clientId)
-	q.Set("client_secret", h.clientSecret)
-	q.Set("code", payload.Code)
-	q.Set("redirect_uri", h.baseUrl+"/auth/callback")
-	u := fmt.Sprintf("https://github.com/login/oauth/access_token?%s", q.Encode())
-	req, err := http.NewRequest("POST", u, nil)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error creating request to github", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
-	req.Header.Set("Accept", "application/json")
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error sending request to github", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
-
-	oauth := model.GHAccessToken{}
-
-	if err = json.NewDecoder(res.Body).Decode(&oauth); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error decoding github response", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+		return signInFailed(fmt.Errorf("validating payload: %w", err))
 	}
 
-	if err = h.validate.Struct(oauth); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error validating github response", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+	// check the state, then trade the code for an access token
+	token, sub, err := h.auth.Complete(ctx, r.PathValue("provider"), attempt, payload.State, payload.Code)
+	if errors.Is(err, auth.ErrUnknownProvider) {
+		return mux.NewError(http.StatusNotFound, "There's no way to sign in with that.", err)
+	} else if errors.Is(err, auth.ErrStateMismatch) || errors.Is(err, auth.ErrNonceMismatch) {
+		return signInFailed(fmt.Errorf("verifying sign in: %w", err))
+	} else if err != nil {
+		return fmt.Errorf("completing sign in: %w", err)
 	}
 
-	user, err := h.currentuser.GetUserByAccessToken(ctx, oauth.AccessToken)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error getting user from github", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
-	}
-
-	sub := model.Subject{User: user.GetLogin()}
-
-	if has, err := h.enforcer.Enforce(sub, "/auth/callback", "GET"); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error enforcing policy", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+	// policies allow signing in on /auth/callback, whichever provider is used.
+	if has, err := h.enforcer.Enforce(*sub, "/auth/callback", "GET"); err != nil {
+		return fmt.Errorf("enforcing policy: %w", err)
 	} else if !has {
-		w.WriteHeader(http.StatusForbidden)
-		logger.Warn("User does not have permission")
-		render.Page(w, r, nil, P{"Why are you here?"})
-		return
+		result = model.AuditDenied
+		return mux.NewError(http.StatusForbidden, "Why are you here?",
+			fmt.Errorf("%s isn't allowed to sign in", sub.User))
 	}
 
 	/*
 		TODO: signup restrictions.
 		if has, err := access.Enforcer(ctx).
 			Enforce(sub, access.Path(ctx), r.Method); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.Error("Error enforcing policy", "error", err)
-			render.Page(w, r, nil, P{"An error has occurred."})
-			return
+			return fmt.Errorf("enforcing policy: %w", err)
 		} else if !has {
-			w.WriteHeader(http.StatusForbidden)
-			logger.Warn("User does not have permission")
-			render.Page(w, r, nil, P{"Why are you here?"})
-			return
+			return mux.NewError(http.StatusForbidden, "Why are you here?", nil)
 		}
 	*/
 
-	sessionCookie := cookie.Session
-	sessionCookie.Value = access.Session(ctx)
-	sessionCookie.MaxAge = 60 * 60 * 5 // 5 hours
-	logger.Info("Creating session", "session", sessionCookie.Value, "oauth", oauth)
-
-	cacheDuration := time.Duration(sessionCookie.MaxAge) * time.Second
-
-	if err = h.accessTokens.Set(ctx, sessionCookie.Value, oauth, cacheDuration); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error storing session in cache", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+	// the session gets a new id on sign in, to prevent session fixation
+	session, err := h.sessions.Login(ctx, r, access.Session(ctx), *sub, *token)
+	if err != nil {
+		return fmt.Errorf("creating session: %w", err)
 	}
+	logger.Info("Created session", "session", session.ID, "user", session.User)
 
-	if err = h.subjects.Set(ctx, sessionCookie.Value, sub, cacheDuration); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error storing subject in cache", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+	err = h.sessionCookie.WithMaxAge(h.sessions.IdleTimeout()).Set(w, &cookie.SessionValue{ID: session.ID})
+	if err != nil {
+		return fmt.Errorf("setting session cookie: %w", err)
+	}
+	result = model.AuditSuccess
+
+	// the state is encrypted, but check next again rather than trusting it
+	// to only ever hold a local url.
+	if next, ok := utils.LocalURL(attempt.Next, h.baseUrl); ok {
+		logger.Info("Returning to the page that required signing in", "next", next)
+		if err := h.sessions.AddFlash(ctx, session, fmt.Sprintf("Signed in as %s.", session.User)); err != nil {
+			logger.Warn("Error adding flash message", "error", err)
+		}
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return nil
 	}
 
-	http.SetCookie(w, &sessionCookie)
 	w.WriteHeader(http.StatusOK)
 	render.Page(w, r, nil,
 		components.Header{},
 		components.Margins(P{"Authenticated!"}))
+	return nil
+}
+
+// signInFailed is an attempt to sign in that couldn't be completed, which
+// isn't explained further to whoever made it.
+func signInFailed(err error) error {
+	return mux.NewError(http.StatusUnauthorized, "Signing in failed, please try again.", err)
 }
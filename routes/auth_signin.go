@@ -1,94 +1,93 @@
 package routes
 
 import (
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 
 	"github.com/Gardego5/garrettdavis.dev/components"
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/auth"
+	"github.com/Gardego5/garrettdavis.dev/utils"
 	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
-	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
 	. "github.com/Gardego5/htmdsl"
 	. "github.com/Gardego5/htmdsl/util"
+	"github.com/elliotchance/pie/v2"
 )
 
 type AuthSignin struct {
-	clientId string
-	block    cipher.Block
-	baseUrl  string
+	auth        *auth.Service
+	stateCookie *cookie.Codec[cookie.StateValue]
+	baseUrl     string
 }
 
 func NewAuthSignin(
-	clientId string,
-	block cipher.Block,
+	auth *auth.Service,
+	stateCookie *cookie.Codec[cookie.StateValue],
 	baseUrl string,
 ) *AuthSignin {
 	return &AuthSignin{
-		clientId: clientId,
-		block:    block,
-		baseUrl:  baseUrl,
+		auth:        auth,
+		stateCookie: stateCookie,
+		baseUrl:     baseUrl,
 	}
 }
 
+// GET lists the providers that can be signed in with. The next query
+// parameter is where to return to afterwards.
 func (h *AuthSignin) GET(w http.ResponseWriter, r *http.Request) {
-	c, _ := r.Cookie(cookie.State.Name)
+	next, _ := utils.LocalURL(r.URL.Query().Get("next"), h.baseUrl)
 
 	render.Page(w, r, nil,
-		components.Header{},
-		components.Margins{Form{Attrs{"method": "post", "action": "/auth/signin"}},
-			Button{Attrs{"type": "submit"}, "Sign in"},
-			If(c != nil, func() any {
-				value, _ := symetric.Decrypt(h.block, c.Value)
-				return P{"Cookie: ", base64.RawURLEncoding.EncodeToString([]byte(value))}
-			}),
+		components.Header{Title: "Sign in"},
+		components.Margins{
+			Div{Class("flex flex-col items-start gap-2"),
+				pie.Map(h.auth.Providers(), func(p auth.Provider) any {
+					return components.Form{Attrs{"method": "post", "action": "/auth/" + p.Name() + "/signin"},
+						If(next != "", Input{"type": "hidden", "name": "next", "value": next}),
+						Button{Class("rounded-sm border border-slate-500 px-4 py-1 hover:bg-slate-800"),
+							Attrs{"type": "submit"},
+							"Sign in with ", p.Title(),
+						},
+					}
+				}),
+			},
 		})
 }
 
-func (h *AuthSignin) POST(w http.ResponseWriter, r *http.Request) {
-	logger := access.Logger(r.Context(), "PostAuth")
+// POST starts signing in with the provider in the path.
+func (h *AuthSignin) POST(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAuthSignin").With("provider", r.PathValue("provider"))
 
-	codeBytes := make([]byte, 40)
-	if _, err := rand.Read(codeBytes); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error generating code for authentication", "error", err)
-		render.Page(w, r, nil, P{"An error has occurred."})
-		return
+	provider, err := h.auth.Lookup(r.PathValue("provider"))
+	if errors.Is(err, auth.ErrUnknownProvider) {
+		return mux.NewError(http.StatusNotFound, "There is no way to sign in with that.", err)
 	}
 
-	state := base64.RawURLEncoding.EncodeToString(codeBytes)
-
-	encryptedCode, err := symetric.Encrypt(h.block, state)
+	href, attempt, err := h.auth.Begin(ctx, provider.Name())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Error("Error encrypting code for authentication", "error", err)
-		render.Page(w, r, nil,
-			components.Header{},
-			components.Margins(P{"An error has occurred."}))
-		return
+		return fmt.Errorf("starting authentication: %w", err)
 	}
 
-	c := cookie.State
-	c.Value = encryptedCode
-	http.SetCookie(w, &c)
+	// only ever return to this site after signing in
+	if next, ok := utils.LocalURL(r.FormValue("next"), h.baseUrl); ok {
+		attempt.Next = next
+	}
+
+	if err := h.stateCookie.Set(w, attempt); err != nil {
+		return fmt.Errorf("encrypting state for authentication: %w", err)
+	}
 
-	redirectUri := h.baseUrl + "/auth/callback"
-	logger.Info("Redirecting to github for authentication", "redirect_uri", redirectUri)
-	q := url.Values{}
-	q.Set("client_id", h.clientId)
-	q.Set("redirect_uri", redirectUri)
-	q.Set("allow_signup", "false")
-	q.Set("state", state)
-	href := fmt.Sprintf("https://github.com/login/oauth/authorize?%s", q.Encode())
+	logger.Info("Redirecting to provider for authentication")
 	render.Page(w, r, nil,
 		components.Header{},
 		components.Margins{Attrs{"x-data": nil, "x-init": "$refs.authorize.click()"},
-			P{"Redirecting to github for authentication..."},
+			P{"Redirecting to ", provider.Title(), " for authentication..."},
 			P{"If you are not redirected, click the link below."},
-			A{Attrs{"href": href, "x-ref": "authorize"}, "Sign in with Github"},
+			A{Attrs{"href": href, "x-ref": "authorize"}, "Sign in with ", provider.Title()},
 		})
+	return nil
 }
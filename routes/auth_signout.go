@@ -1,20 +1,101 @@
 package routes
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/access"
+	"github.com/Gardego5/garrettdavis.dev/service/audit"
+	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
 	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
 )
 
-type AuthSignout struct{}
+type AuthSignout struct {
+	sessions *session.Service
+	revoker  currentuser.TokenRevoker
+	audit    *audit.Service
+}
 
-func NewAuthSignout() *AuthSignout {
-	return &AuthSignout{}
+func NewAuthSignout(
+	sessions *session.Service,
+	revoker currentuser.TokenRevoker,
+	audit *audit.Service,
+) *AuthSignout {
+	return &AuthSignout{sessions: sessions, revoker: revoker, audit: audit}
 }
 
-func (*AuthSignout) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// session := middleware.GetSession(r.Context())
+// POST signs out of the current session, revoking its access token.
+func (h *AuthSignout) POST(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAuthSignout")
+	id := access.Session(ctx)
+
+	// the event is made while the session still says who's signing out.
+	event := access.AuditEvent(ctx, model.AuditSignOut, "", model.AuditFailure)
+	event.Target = event.Actor
+	defer func() { recordAudit(ctx, h.audit, event) }()
+
+	token, err := h.sessions.Token(ctx, id)
+	if err != nil && !errors.Is(err, bimarshal.ErrNotFound) {
+		logger.Error("Error getting access token", "error", err)
+	}
+
+	if err := h.sessions.Revoke(ctx, id); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+
+	// the session is already gone locally, so a failure here doesn't keep
+	// the user signed in. the token just stays valid with github.
+	if token != nil && token.IsGithub() {
+		if err := h.revoker.RevokeToken(ctx, token.AccessToken); err != nil {
+			logger.Error("Error revoking access token", "error", err)
+		}
+	}
+
+	event.Result = model.AuditSuccess
+	logger.Info("Signed out", "session", id)
 	cookie.Delete(w, cookie.Session)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
 
+// PostEverywhere signs out of every session signed in as the current user,
+// revoking the user's authorization of this application with github.
+func (h *AuthSignout) PostEverywhere(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	logger := access.Logger(ctx, "PostAuthSignoutEverywhere")
+
+	data := access.SessionData(ctx)
+	if data == nil || data.User == "" {
+		return h.POST(w, r)
+	}
+
+	event := access.AuditEvent(ctx, model.AuditSignOutEverywhere, data.User, model.AuditFailure)
+	defer func() { recordAudit(ctx, h.audit, event) }()
+
+	token, err := h.sessions.Token(ctx, data.ID)
+	if err != nil && !errors.Is(err, bimarshal.ErrNotFound) {
+		logger.Error("Error getting access token", "error", err)
+	}
+
+	sessions, err := h.sessions.RevokeUser(ctx, data.User)
+	if err != nil {
+		return fmt.Errorf("revoking sessions: %w", err)
+	}
+
+	if token != nil && token.IsGithub() {
+		if err := h.revoker.RevokeGrant(ctx, token.AccessToken); err != nil {
+			logger.Error("Error revoking authorization", "error", err)
+		}
+	}
+
+	event.Result = model.AuditSuccess
+	logger.Info("Signed out everywhere", "user", data.User, "sessions", len(sessions))
+	cookie.Delete(w, cookie.Session)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
 }
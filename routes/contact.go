@@ -14,6 +14,7 @@ import (
 	"github.com/Gardego5/garrettdavis.dev/resource/access"
 	"github.com/Gardego5/garrettdavis.dev/resource/render"
 	"github.com/Gardego5/garrettdavis.dev/service/messages"
+	"github.com/Gardego5/garrettdavis.dev/utils/mux"
 	. "github.com/Gardego5/htmdsl"
 	. "github.com/Gardego5/htmdsl/util"
 	"github.com/go-playground/validator/v10"
@@ -46,7 +47,7 @@ func (*Contact) GET(w http.ResponseWriter, r *http.Request) {
 
 	render.Page(w, r, Title{"Contact Garrett"},
 		components.Header{},
-		components.Margins{Form{Class("relative grid gap-2 rounded-sm border border-slate-500 bg-gray-200 dark:bg-gray-800 p-4 sm:grid-cols-2 md:grid-cols-3 mt-8"),
+		components.Margins{components.Form{Class("relative grid gap-2 rounded-sm border border-slate-500 bg-gray-200 dark:bg-gray-800 p-4 sm:grid-cols-2 md:grid-cols-3 mt-8"),
 			Attrs{"action": "/contact", "method": "POST", "hx-swap": "innerHTML", "hx-target-error": "#form-error"},
 			H2{Class("text-2xl font-semibold col-span-full"),
 				"I'd love to hear from you!",
@@ -105,13 +106,12 @@ func (*Contact) GET(w http.ResponseWriter, r *http.Request) {
 }
 
 type contactMessageErrors struct {
-	General, Name, Email, Message error
+	Name, Email, Message error
 }
 
 func (c contactMessageErrors) Render(context.Context) RenderedHTML {
 	return Fragment{
 		Span{Switch().
-			Case(c.General != nil, func() any { return c.General.Error() }).
 			Case(c.Name == nil && c.Email == nil && c.Message == nil, "Some unkown error occurred. Please try again.").
 			Default("Please fix these errors.")},
 		Div{Attrs{"hx-swap-oob": "innerHTML:#name-error"},
@@ -123,17 +123,13 @@ func (c contactMessageErrors) Render(context.Context) RenderedHTML {
 	}
 }
 
-func (h *Contact) POST(w http.ResponseWriter, r *http.Request) {
+func (h *Contact) POST(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	logger := access.Logger(ctx, "PostContact")
 
+	// the form isn't logged, it has the csrf token and the sender's details.
 	if err := r.ParseForm(); err != nil {
-		logger.Error("error parsing form", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		Render(w, contactMessageErrors{General: err})
-		return
-	} else {
-		logger.Info("form parsed", "form", r.Form)
+		return mux.NewError(http.StatusBadRequest, "The form couldn't be read, please try again.", err)
 	}
 
 	body := model.ContactMessage{
@@ -158,7 +154,7 @@ func (h *Contact) POST(w http.ResponseWriter, r *http.Request) {
 		logger.Warn("validation error", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		Render(w, resp)
-		return
+		return nil
 	}
 
 	// escape html... just in case
@@ -166,12 +162,10 @@ func (h *Contact) POST(w http.ResponseWriter, r *http.Request) {
 	body.Message = html.EscapeString(body.Message)
 
 	if err := h.messages.CreateMessage(ctx, &body); err != nil {
-		logger.Error("error inserting contact message", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		Render(w, contactMessageErrors{General: err})
-		return
+		return fmt.Errorf("inserting contact message: %w", err)
 	}
 
 	Render(w, Div{Class("col-span-full text-center text-xl"),
 		"Thanks ", body.Name, ", I'll get back to you soon."})
+	return nil
 }
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/resource/render"
+	"github.com/Gardego5/garrettdavis.dev/service/health"
+)
+
+type Health struct {
+	health *health.Service
+}
+
+func NewHealth(health *health.Service) *Health {
+	return &Health{health: health}
+}
+
+// GetHealthz is whether the process is alive, which it is if it can answer.
+func (h *Health) GetHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, http.StatusOK, map[string]health.Status{"status": health.StatusOK})
+}
+
+// GetReadyz is whether every dependency is usable, so that requests should be
+// routed here.
+func (h *Health) GetReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Ready(r.Context())
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, status, report)
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type Service struct {
+	db          *sqlx.DB
+	createEvent *sqlx.NamedStmt
+	listEvents  *sqlx.NamedStmt
+	countEvents *sqlx.NamedStmt
+	now         func() time.Time
+}
+
+func New(db *sqlx.DB) (*Service, error) {
+	svc, err := Service{db: db, now: time.Now}, error(nil)
+
+	if svc.createEvent, err = svc.db.PrepareNamed(`
+INSERT INTO audit_events
+     ( actor
+     , action
+     , target
+     , ip
+     , request_id
+     , result
+     , created_at)
+VALUES (:actor, :action, :target, :ip, :request_id, :result, :created_at)`); err != nil {
+		return nil, err
+	}
+
+	// empty filters match everything.
+	const FILTER = `
+  FROM audit_events
+ WHERE (:actor = '' OR actor = :actor)
+   AND (:action = '' OR action = :action)
+   AND (:result = '' OR result = :result)
+   AND (:since = '' OR created_at >= :since)
+   AND (:until = '' OR created_at < :until)
+`
+
+	if svc.listEvents, err = svc.db.PrepareNamed(`
+SELECT *` + FILTER + `
+ ORDER BY id DESC
+ LIMIT :limit
+OFFSET :offset
+`); err != nil {
+		return nil, err
+	}
+
+	if svc.countEvents, err = svc.db.PrepareNamed(`
+SELECT COUNT(*)` + FILTER); err != nil {
+		return nil, err
+	}
+
+	return &svc, nil
+}
+
+// Record appends event to the audit log, at the current time.
+func (svc *Service) Record(ctx context.Context, event model.AuditEvent) error {
+	// times are stored as text, so they're kept in utc to sort correctly.
+	event.CreatedAt = model.Time{Time: svc.now().UTC()}
+	_, err := svc.createEvent.ExecContext(ctx, event)
+	return err
+}
+
+type ListEventsInput struct {
+	Actor  string
+	Action model.AuditAction
+	Result model.AuditResult
+	// Since and Until bound when events happened, when they aren't zero.
+	// Until is exclusive.
+	Since, Until time.Time
+	// Limit is the most events to list, or every event when it's 0.
+	Limit  int
+	Offset int
+}
+
+func (in *ListEventsInput) params() map[string]any {
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(model.TimeFormat)
+	}
+
+	limit := in.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
+	return map[string]any{
+		"actor":  in.Actor,
+		"action": in.Action,
+		"result": in.Result,
+		"since":  timestamp(in.Since),
+		"until":  timestamp(in.Until),
+		"limit":  limit,
+		"offset": in.Offset,
+	}
+}
+
+// ListEvents lists the events matching the filters of input, newest first.
+func (svc *Service) ListEvents(ctx context.Context, input *ListEventsInput) (out []model.AuditEvent, err error) {
+	err = svc.listEvents.SelectContext(ctx, &out, input.params())
+	return
+}
+
+func (svc *Service) CountEvents(ctx context.Context, input *ListEventsInput) (count int, err error) {
+	err = svc.countEvents.GetContext(ctx, &count, input.params())
+	return
+}
+
+func (svc *Service) Close() error {
+	return errors.Join(
+		svc.createEvent.Close(),
+		svc.listEvents.Close(),
+		svc.countEvents.Close(),
+	)
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/Gardego5/htmdsl"
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	fakeKeyId    = "fake"
+	fakeCodeTTL  = time.Minute
+	fakeTokenTTL = time.Hour
+)
+
+type (
+	// Fake is a minimal openid connect provider for local development and
+	// end to end tests. It signs anyone in as whoever they say they are, so
+	// it must never be enabled in production.
+	//
+	// It enforces pkce, and includes the nonce in the id tokens it issues,
+	// so that it exercises the same checks as a real provider.
+	Fake struct {
+		issuer string
+		key    *rsa.PrivateKey
+		mux    *http.ServeMux
+
+		mu     sync.Mutex
+		grants map[string]fakeGrant
+	}
+	fakeGrant struct {
+		clientId, redirectURI, challenge, nonce, login string
+		expires                                        time.Time
+	}
+)
+
+var _ http.Handler = (*Fake)(nil)
+
+// NewFake creates a provider that is served at issuer.
+func NewFake(issuer string) (*Fake, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Fake{
+		issuer: strings.TrimSuffix(issuer, "/"),
+		key:    key,
+		mux:    http.NewServeMux(),
+		grants: map[string]fakeGrant{},
+	}
+	f.mux.HandleFunc("GET /.well-known/openid-configuration", f.discovery)
+	f.mux.HandleFunc("GET /authorize", f.authorize)
+	f.mux.HandleFunc("POST /token", f.token)
+	f.mux.HandleFunc("GET /jwks", f.jwks)
+	return f, nil
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) { f.mux.ServeHTTP(w, r) }
+
+func (f *Fake) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                f.issuer,
+		"authorization_endpoint":                f.issuer + "/authorize",
+		"token_endpoint":                        f.issuer + "/token",
+		"jwks_uri":                              f.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize asks who to sign in as, then redirects back with a code. Tests
+// can skip the form by adding login to the authorization url.
+func (f *Fake) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" || q.Get("client_id") == "" {
+		http.Error(w, "client_id and redirect_uri are required", http.StatusBadRequest)
+		return
+	} else if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "a S256 code_challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	login := q.Get("login")
+	if login == "" {
+		hidden := []any{}
+		for k := range q {
+			if k == "login" {
+				continue
+			}
+			hidden = append(hidden, Input{"type": "hidden", "name": k, "value": q.Get(k)})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		Render(w, Html{Body{Form{Attrs{"method": "GET"},
+			hidden,
+			Label{"Sign in to the fake provider as ", Input{"name": "login", "required": nil, "autofocus": nil}},
+			Button{Attrs{"type": "submit"}, "Sign in"},
+		}}})
+		return
+	}
+
+	code := newToken()
+	f.mu.Lock()
+	f.grants[code] = fakeGrant{
+		clientId:    q.Get("client_id"),
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		login:       login,
+		expires:     time.Now().Add(fakeCodeTTL),
+	}
+	f.mu.Unlock()
+
+	back := redirectURI.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (f *Fake) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientId, _, ok := r.BasicAuth()
+	if !ok {
+		clientId = r.PostForm.Get("client_id")
+	}
+
+	// codes can only be used once, even when the exchange fails.
+	code := r.PostForm.Get("code")
+	f.mu.Lock()
+	grant, ok := f.grants[code]
+	delete(f.grants, code)
+	f.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || time.Now().After(grant.expires) ||
+		r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		clientId != grant.clientId ||
+		subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(challenge[:])), []byte(grant.challenge)) != 1 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := f.sign(map[string]any{
+		"iss":                f.issuer,
+		"sub":                grant.login,
+		"aud":                grant.clientId,
+		"iat":                now.Unix(),
+		"exp":                now.Add(fakeTokenTTL).Unix(),
+		"nonce":              grant.nonce,
+		"preferred_username": grant.login,
+		// anyone can sign in as anyone, so nothing about them is verified.
+		"email":          grant.login + "@fake.invalid",
+		"email_verified": false,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": newToken(),
+		"token_type":   "Bearer",
+		"expires_in":   int(fakeTokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (f *Fake) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &f.key.PublicKey,
+		KeyID:     fakeKeyId,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}})
+}
+
+func (f *Fake) sign(claims map[string]any) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: f.key, KeyID: fakeKeyId}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/service/currentuser"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+type githubProvider struct {
+	config oauth2.Config
+	users  *currentuser.Service
+}
+
+var _ Provider = (*githubProvider)(nil)
+
+// NewGithub signs in with a github oauth application. Subjects are the
+// github login of the user, and the organizations and teams they're a member
+// of, which are looked up through users so that they're cached for the
+// session.
+func NewGithub(clientId, clientSecret string, users *currentuser.Service) Provider {
+	return &githubProvider{
+		config: oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: clientSecret,
+			Endpoint:     github.Endpoint,
+			// read:org lists private organization and team memberships.
+			Scopes: []string{"read:org"},
+		},
+		users: users,
+	}
+}
+
+func (p *githubProvider) Name() string  { return model.ProviderGithub }
+func (p *githubProvider) Title() string { return "GitHub" }
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, redirectURL string, attempt *cookie.StateValue) (string, error) {
+	return authCodeURL(p.config, redirectURL, attempt, oauth2.SetAuthURLParam("allow_signup", "false")), nil
+}
+
+func (p *githubProvider) Exchange(
+	ctx context.Context,
+	redirectURL, code string,
+	attempt *cookie.StateValue,
+) (*model.AccessToken, *model.Subject, error) {
+	token, err := exchange(ctx, p.config, redirectURL, code, attempt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := p.users.GetUserByAccessToken(ctx, token.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	} else if user.GetLogin() == "" {
+		return nil, nil, errors.New("auth: github user doesn't have a login")
+	}
+
+	groups, err := p.users.GetGroupsByAccessToken(ctx, token.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return accessToken(p.Name(), token),
+		&model.Subject{Provider: p.Name(), User: user.GetLogin(), Groups: groups}, nil
+}
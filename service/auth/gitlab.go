@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"golang.org/x/oauth2"
+)
+
+type gitlabProvider struct {
+	config  oauth2.Config
+	baseUrl string
+}
+
+var _ Provider = (*gitlabProvider)(nil)
+
+// NewGitlab signs in with a gitlab application, on gitlab.com or a self
+// hosted instance at baseUrl. Subjects are the gitlab username of the user,
+// ie. "gitlab/someone".
+func NewGitlab(baseUrl, clientId, clientSecret string) Provider {
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	return &gitlabProvider{
+		config: oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  baseUrl + "/oauth/authorize",
+				TokenURL: baseUrl + "/oauth/token",
+			},
+			Scopes: []string{"read_user"},
+		},
+		baseUrl: baseUrl,
+	}
+}
+
+func (p *gitlabProvider) Name() string  { return model.ProviderGitlab }
+func (p *gitlabProvider) Title() string { return "GitLab" }
+
+func (p *gitlabProvider) AuthCodeURL(ctx context.Context, redirectURL string, attempt *cookie.StateValue) (string, error) {
+	return authCodeURL(p.config, redirectURL, attempt), nil
+}
+
+func (p *gitlabProvider) Exchange(
+	ctx context.Context,
+	redirectURL, code string,
+	attempt *cookie.StateValue,
+) (*model.AccessToken, *model.Subject, error) {
+	token, err := exchange(ctx, p.config, redirectURL, code, attempt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseUrl+"/api/v4/user", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := p.config.Client(ctx, token).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("auth: getting gitlab user: %s", res.Status)
+	}
+
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+		return nil, nil, err
+	} else if user.Username == "" {
+		return nil, nil, errors.New("auth: gitlab user doesn't have a username")
+	}
+
+	return accessToken(p.Name(), token), &model.Subject{Provider: p.Name(), User: p.Name() + "/" + user.Username}, nil
+}
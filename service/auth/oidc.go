@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+type OIDCConfig struct {
+	// Name identifies the provider in urls and subjects.
+	Name string
+	// Title is shown to users choosing how to sign in.
+	Title string
+	// Issuer is the url of the provider, its configuration is discovered
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// UserClaim is the id token claim identifying the user. It must be
+	// unique, and never given to someone else, which is why it's usually
+	// "sub". "email" is only accepted when the provider has verified it.
+	// Claims users can change themselves, like "preferred_username", would
+	// let them sign in as someone else.
+	UserClaim string
+}
+
+type (
+	oidcProvider struct {
+		cfg OIDCConfig
+
+		mu         sync.Mutex
+		discovered *oidcDiscovered
+	}
+	oidcDiscovered struct {
+		config   oauth2.Config
+		verifier *oidc.IDTokenVerifier
+	}
+)
+
+var _ Provider = (*oidcProvider)(nil)
+
+// NewOIDC signs in with any openid connect provider. Subjects are the
+// UserClaim of the id token, prefixed with the provider's name.
+func NewOIDC(cfg OIDCConfig) Provider { return &oidcProvider{cfg: cfg} }
+
+func (p *oidcProvider) Name() string  { return p.cfg.Name }
+func (p *oidcProvider) Title() string { return p.cfg.Title }
+
+// discover fetches the configuration of the provider the first time it is
+// used, so that the provider being down doesn't keep the application from
+// starting. Failures are retried the next time someone signs in.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovered, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered != nil {
+		return p.discovered, nil
+	}
+
+	// the provider holds onto ctx to refresh the signing keys later, so it
+	// can't be cancelled along with the request.
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), p.cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	p.discovered = &oidcDiscovered{
+		config: oauth2.Config{
+			ClientID:     p.cfg.ClientId,
+			ClientSecret: p.cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientId}),
+	}
+	return p.discovered, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, redirectURL string, attempt *cookie.StateValue) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(d.config, redirectURL, attempt, oidc.Nonce(attempt.Nonce)), nil
+}
+
+func (p *oidcProvider) Exchange(
+	ctx context.Context,
+	redirectURL, code string,
+	attempt *cookie.StateValue,
+) (*model.AccessToken, *model.Subject, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := exchange(ctx, d.config, redirectURL, code, attempt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, nil, errors.New("auth: token response doesn't have an id token")
+	}
+	idToken, err := d.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(attempt.Nonce)) != 1 {
+		return nil, nil, ErrNonceMismatch
+	}
+
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, nil, err
+	}
+	user, _ := claims[p.cfg.UserClaim].(string)
+	if verified, _ := claims["email_verified"].(bool); p.cfg.UserClaim == "email" && !verified {
+		return nil, nil, fmt.Errorf("%w: the email hasn't been verified", ErrUserClaim)
+	} else if user == "" {
+		return nil, nil, fmt.Errorf("%w: the %q claim is missing", ErrUserClaim, p.cfg.UserClaim)
+	}
+
+	return accessToken(p.Name(), token), &model.Subject{Provider: p.Name(), User: p.Name() + "/" + user}, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"golang.org/x/oauth2"
+)
+
+// Provider is an oauth authorization server users can sign in with.
+type Provider interface {
+	// Name identifies the provider in urls, ie. /auth/{name}/signin.
+	Name() string
+	// Title is shown to users choosing how to sign in.
+	Title() string
+	// AuthCodeURL is where the user is sent to sign in for attempt.
+	AuthCodeURL(ctx context.Context, redirectURL string, attempt *cookie.StateValue) (string, error)
+	// Exchange trades the code the user came back with for an access token,
+	// and maps the user it belongs to into a subject.
+	Exchange(ctx context.Context, redirectURL, code string, attempt *cookie.StateValue) (*model.AccessToken, *model.Subject, error)
+}
+
+// authCodeURL and exchange are the parts of the authorization code flow that
+// are the same for every provider.
+
+func authCodeURL(
+	config oauth2.Config,
+	redirectURL string,
+	attempt *cookie.StateValue,
+	opts ...oauth2.AuthCodeOption,
+) string {
+	config.RedirectURL = redirectURL
+	opts = append(opts, oauth2.S256ChallengeOption(attempt.Verifier))
+	return config.AuthCodeURL(attempt.State, opts...)
+}
+
+func exchange(
+	ctx context.Context,
+	config oauth2.Config,
+	redirectURL, code string,
+	attempt *cookie.StateValue,
+) (*oauth2.Token, error) {
+	config.RedirectURL = redirectURL
+	return config.Exchange(ctx, code, oauth2.VerifierOption(attempt.Verifier))
+}
+
+func accessToken(provider string, token *oauth2.Token) *model.AccessToken {
+	scope, _ := token.Extra("scope").(string)
+	return &model.AccessToken{
+		Provider:    provider,
+		AccessToken: token.AccessToken,
+		Scope:       scope,
+		TokenType:   token.Type(),
+	}
+}
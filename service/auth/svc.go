@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+var tracer = otel.Tracer("github.com/Gardego5/garrettdavis.dev/service/auth")
+
+var (
+	ErrUnknownProvider = errors.New("auth: unknown provider")
+	ErrStateMismatch   = errors.New("auth: state mismatch")
+	ErrNonceMismatch   = errors.New("auth: nonce mismatch")
+	ErrUserClaim       = errors.New("auth: id token doesn't identify the user")
+)
+
+// Service signs users in with any of the configured oauth providers, using
+// the authorization code flow with pkce.
+type Service struct {
+	baseUrl   string
+	providers []Provider
+}
+
+func New(baseUrl string, providers ...Provider) *Service {
+	return &Service{baseUrl: strings.TrimSuffix(baseUrl, "/"), providers: providers}
+}
+
+// Providers returns every configured provider, in the order they were
+// configured.
+func (s *Service) Providers() []Provider { return s.providers }
+
+func (s *Service) Lookup(name string) (Provider, error) {
+	for _, p := range s.providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+func (s *Service) redirectURL(p Provider) string {
+	return s.baseUrl + "/auth/" + p.Name() + "/callback"
+}
+
+// start starts a span for op with the provider called name. Requests to the
+// provider are sent with a client that traces them, which oauth2 and oidc
+// take from the context.
+func start(ctx context.Context, op, name string) (context.Context, trace.Span) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, tracing.Client)
+	return tracer.Start(ctx, "auth."+op, trace.WithAttributes(attribute.String("auth.provider", name)))
+}
+
+// Begin starts signing in with the provider called name. It returns the url
+// to send the user to, and the attempt which the client must hold on to, in
+// the state cookie, until the provider redirects back to the callback.
+func (s *Service) Begin(ctx context.Context, name string) (_ string, _ *cookie.StateValue, err error) {
+	ctx, span := start(ctx, "Begin", name)
+	defer func() { tracing.End(span, err) }()
+
+	p, err := s.Lookup(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	attempt := &cookie.StateValue{
+		Provider: p.Name(),
+		State:    newToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    newToken(),
+	}
+
+	u, err := p.AuthCodeURL(ctx, s.redirectURL(p), attempt)
+	if err != nil {
+		return "", nil, err
+	}
+	return u, attempt, nil
+}
+
+// Complete finishes the attempt to sign in with the provider called name,
+// using the state and code the provider redirected back with.
+func (s *Service) Complete(
+	ctx context.Context,
+	name string,
+	attempt *cookie.StateValue,
+	state, code string,
+) (_ *model.AccessToken, _ *model.Subject, err error) {
+	ctx, span := start(ctx, "Complete", name)
+	defer func() { tracing.End(span, err) }()
+
+	p, err := s.Lookup(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if attempt.Provider != p.Name() ||
+		subtle.ConstantTimeCompare([]byte(attempt.State), []byte(state)) != 1 {
+		return nil, nil, ErrStateMismatch
+	}
+
+	return p.Exchange(ctx, s.redirectURL(p), code, attempt)
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
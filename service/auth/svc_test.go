@@ -0,0 +1,145 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/service/auth"
+	"github.com/Gardego5/garrettdavis.dev/utils/cookie"
+)
+
+// fake starts a fake provider, and a service that signs in with it, with
+// users identified by userClaim.
+func fake(t *testing.T, userClaim string) *Service {
+	srv := httptest.NewUnstartedServer(nil)
+	f, err := NewFake("http://" + srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.Handler = f
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return New("https://example.com", NewOIDC(OIDCConfig{
+		Name:      "fake",
+		Title:     "Fake",
+		Issuer:    srv.URL,
+		ClientId:  "client",
+		UserClaim: userClaim,
+	}))
+}
+
+// authorize signs in to the provider as login, returning the state and code
+// it redirects back with.
+func authorize(t *testing.T, authURL, login string) (state, code string) {
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	q.Set("login", login)
+	u.RawQuery = q.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("expected a redirect, got %s", res.Status)
+	}
+
+	callback, _ := url.Parse(res.Header.Get("Location"))
+	if callback.Path != "/auth/fake/callback" {
+		t.Errorf("expected to be redirected to the callback, got %s", callback)
+	}
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestSignIn(t *testing.T) {
+	ctx := context.Background()
+	svc := fake(t, "sub")
+
+	authURL, attempt, err := svc.Begin(ctx, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, _ := url.Parse(authURL); q.Query().Get("code_challenge") == "" || q.Query().Get("nonce") != attempt.Nonce {
+		t.Errorf("expected a pkce challenge and nonce, got %s", authURL)
+	}
+
+	state, code := authorize(t, authURL, "someone")
+	token, sub, err := svc.Complete(ctx, "fake", attempt, state, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Provider != "fake" || sub.User != "fake/someone" {
+		t.Errorf("expected fake/someone, got %+v", sub)
+	}
+	if token.Provider != "fake" || token.AccessToken == "" {
+		t.Errorf("expected an access token from fake, got %+v", token)
+	}
+
+	// codes can't be used twice
+	if _, _, err := svc.Complete(ctx, "fake", attempt, state, code); err == nil {
+		t.Error("expected a used code to be rejected")
+	}
+}
+
+func TestSignInRejected(t *testing.T) {
+	ctx := context.Background()
+	svc := fake(t, "sub")
+
+	for name, test := range map[string]struct {
+		tamper func(state *string, attempt *cookie.StateValue)
+		err    error
+	}{
+		"state": {func(state *string, _ *cookie.StateValue) { *state = "forged" }, ErrStateMismatch},
+		"pkce":  {func(_ *string, a *cookie.StateValue) { a.Verifier = "not the verifier of the challenge" }, nil},
+		"nonce": {func(_ *string, a *cookie.StateValue) { a.Nonce = "replayed" }, ErrNonceMismatch},
+	} {
+		t.Run(name, func(t *testing.T) {
+			authURL, attempt, err := svc.Begin(ctx, "fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, code := authorize(t, authURL, "someone")
+			test.tamper(&state, attempt)
+
+			_, _, err = svc.Complete(ctx, "fake", attempt, state, code)
+			if err == nil {
+				t.Fatal("expected sign in to be rejected")
+			} else if test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	if _, _, err := svc.Begin(ctx, "nope"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestSignInUserClaim(t *testing.T) {
+	ctx := context.Background()
+
+	// only claims that can't be changed by the user, or have been verified,
+	// identify them.
+	for _, claim := range []string{"email", "missing"} {
+		t.Run(claim, func(t *testing.T) {
+			svc := fake(t, claim)
+			authURL, attempt, err := svc.Begin(ctx, "fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, code := authorize(t, authURL, "someone")
+			if _, _, err = svc.Complete(ctx, "fake", attempt, state, code); !errors.Is(err, ErrUserClaim) {
+				t.Errorf("expected ErrUserClaim, got %v", err)
+			}
+		})
+	}
+}
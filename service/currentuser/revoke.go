@@ -0,0 +1,62 @@
+package currentuser
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
+	"github.com/google/go-github/v66/github"
+)
+
+// TokenRevoker invalidates access tokens with the provider that issued them.
+type TokenRevoker interface {
+	// RevokeToken invalidates a single access token.
+	RevokeToken(ctx context.Context, token string) error
+	// RevokeGrant invalidates the whole authorization token belongs to, along
+	// with every other token issued for it.
+	RevokeGrant(ctx context.Context, token string) error
+}
+
+type githubRevoker struct {
+	client   *github.Client
+	clientId string
+}
+
+var _ TokenRevoker = (*githubRevoker)(nil)
+
+// NewGithubRevoker revokes tokens through github's oauth application API,
+// which authenticates using the application's client id & secret.
+func NewGithubRevoker(clientId, clientSecret string) TokenRevoker {
+	tp := github.BasicAuthTransport{Username: clientId, Password: clientSecret, Transport: tracing.Transport}
+	return &githubRevoker{client: github.NewClient(tp.Client()), clientId: clientId}
+}
+
+func (g *githubRevoker) RevokeToken(ctx context.Context, token string) error {
+	_, err := g.client.Authorizations.Revoke(ctx, g.clientId, token)
+	return err
+}
+
+func (g *githubRevoker) RevokeGrant(ctx context.Context, token string) error {
+	_, err := g.client.Authorizations.DeleteGrant(ctx, g.clientId, token)
+	return err
+}
+
+// LocalRevoker stands in for github when tokens shouldn't really be revoked,
+// such as local development & tests. It counts what would have been revoked,
+// without keeping the tokens themselves.
+type LocalRevoker struct {
+	Tokens atomic.Int64
+	Grants atomic.Int64
+}
+
+var _ TokenRevoker = (*LocalRevoker)(nil)
+
+func (l *LocalRevoker) RevokeToken(ctx context.Context, token string) error {
+	l.Tokens.Add(1)
+	return nil
+}
+
+func (l *LocalRevoker) RevokeGrant(ctx context.Context, token string) error {
+	l.Grants.Add(1)
+	return nil
+}
@@ -2,22 +2,29 @@ package currentuser
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/Gardego5/garrettdavis.dev/model"
 	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
 	"github.com/google/go-github/v66/github"
 )
 
+// ErrNotGithub is returned for sessions that signed in with another provider.
+var ErrNotGithub = errors.New("currentuser: session didn't sign in with github")
+
 type Service struct {
 	users        bimarshal.Cache[github.User]
-	accessTokens bimarshal.Cache[model.GHAccessToken]
+	groups       bimarshal.Cache[model.GithubGroups]
+	accessTokens bimarshal.Cache[model.AccessToken]
 }
 
 func New(caches bimarshal.RegisteredCaches) *Service {
 	return &Service{
 		users:        bimarshal.Get[github.User](caches),
-		accessTokens: bimarshal.Get[model.GHAccessToken](caches),
+		groups:       bimarshal.Get[model.GithubGroups](caches),
+		accessTokens: bimarshal.Get[model.AccessToken](caches),
 	}
 }
 
@@ -25,8 +32,8 @@ func (s *Service) GetUserByAccessToken(
 	ctx context.Context,
 	accessToken string,
 ) (*github.User, error) {
-	return s.users.GetOrSet(ctx, accessToken, func() (*github.User, time.Duration, error) {
-		user, _, err := github.NewClient(nil).
+	return s.users.GetOrSet(ctx, accessToken, func(ctx context.Context) (*github.User, time.Duration, error) {
+		user, _, err := github.NewClient(tracing.Client).
 			WithAuthToken(accessToken).Users.Get(ctx, "")
 		return user, 1 * time.Hour, err
 	})
@@ -39,7 +46,68 @@ func (s *Service) GetUserBySession(
 	oauth, err := s.accessTokens.Get(ctx, session)
 	if err != nil {
 		return nil, err
+	} else if !oauth.IsGithub() {
+		return nil, ErrNotGithub
 	}
 
 	return s.GetUserByAccessToken(ctx, oauth.AccessToken)
 }
+
+// GetGroupsByAccessToken lists the organizations ("@org") and teams
+// ("@org/team") the user is a member of. Private memberships are only listed
+// when the token has the read:org scope.
+func (s *Service) GetGroupsByAccessToken(
+	ctx context.Context,
+	accessToken string,
+) ([]string, error) {
+	groups, err := s.groups.GetOrSet(ctx, accessToken, func(ctx context.Context) (*model.GithubGroups, time.Duration, error) {
+		client := github.NewClient(tracing.Client).WithAuthToken(accessToken)
+		groups := model.GithubGroups{}
+
+		for opts := (&github.ListOptions{PerPage: 100}); ; {
+			orgs, resp, err := client.Organizations.List(ctx, "", opts)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, org := range orgs {
+				groups.Groups = append(groups.Groups, "@"+org.GetLogin())
+			}
+			if opts.Page = resp.NextPage; opts.Page == 0 {
+				break
+			}
+		}
+
+		for opts := (&github.ListOptions{PerPage: 100}); ; {
+			teams, resp, err := client.Teams.ListUserTeams(ctx, opts)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, team := range teams {
+				groups.Groups = append(groups.Groups, "@"+team.GetOrganization().GetLogin()+"/"+team.GetSlug())
+			}
+			if opts.Page = resp.NextPage; opts.Page == 0 {
+				break
+			}
+		}
+
+		return &groups, 1 * time.Hour, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups.Groups, nil
+}
+
+func (s *Service) GetGroupsBySession(
+	ctx context.Context,
+	session string,
+) ([]string, error) {
+	oauth, err := s.accessTokens.Get(ctx, session)
+	if err != nil {
+		return nil, err
+	} else if !oauth.IsGithub() {
+		return nil, ErrNotGithub
+	}
+
+	return s.GetGroupsByAccessToken(ctx, oauth.AccessToken)
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailing Status = "failing"
+)
+
+// Check reports whether a dependency is usable, by returning nil.
+type Check func(ctx context.Context) error
+
+type (
+	Report struct {
+		Status Status `json:"status"`
+		// Stopping is whether the server is shutting down, in which case
+		// it's never ready.
+		Stopping bool                   `json:"stopping,omitempty"`
+		Checks   map[string]CheckResult `json:"checks"`
+	}
+	CheckResult struct {
+		Status  Status  `json:"status"`
+		Latency float64 `json:"latency_ms"`
+		// Error is why the check failed. It may say more than should be
+		// public, so it's logged rather than answered with.
+		Error string `json:"-"`
+	}
+)
+
+var ErrTimeout = errors.New("health: check timed out")
+
+type Service struct {
+	checks   map[string]Check
+	stopping <-chan struct{}
+	timeout  time.Duration
+	maxAge   time.Duration
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	last      Report
+	checkedAt time.Time
+}
+
+// New checks the dependencies in checks, each of which must finish within
+// timeout. Their results are reused for maxAge, so checking readiness often
+// doesn't load the dependencies. Nothing is ready once stopping is closed.
+func New(
+	stopping <-chan struct{},
+	timeout, maxAge time.Duration,
+	checks map[string]Check,
+	logger *slog.Logger,
+) *Service {
+	return &Service{
+		checks:   checks,
+		stopping: stopping,
+		timeout:  timeout,
+		maxAge:   maxAge,
+		logger:   logger.With("scope", "health.Service"),
+	}
+}
+
+// Ready reports how each check went, running every check at once when the
+// last results are older than maxAge. Concurrent callers wait for the same
+// run. Whether the server is stopping is never cached.
+func (svc *Service) Ready(ctx context.Context) Report {
+	svc.mu.Lock()
+	if svc.checkedAt.IsZero() || time.Since(svc.checkedAt) >= svc.maxAge {
+		// the results are shared, so they aren't cut short by the request
+		// that happened to run them going away.
+		svc.last, svc.checkedAt = svc.check(context.WithoutCancel(ctx)), time.Now()
+	}
+	report := svc.last
+	svc.mu.Unlock()
+
+	select {
+	case <-svc.stopping:
+		report.Status, report.Stopping = StatusFailing, true
+	default:
+	}
+	return report
+}
+
+// check runs every check at once, logging why any failed.
+func (svc *Service) check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(svc.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range svc.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := svc.run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFailing
+				svc.logger.WarnContext(ctx, "health check failed", "check", name, "error", result.Error)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (svc *Service) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, svc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	// checks that ignore ctx are abandoned, rather than holding up the
+	// report.
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	result := CheckResult{Status: StatusOK, Latency: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status, result.Error = StatusFailing, err.Error()
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/service/health"
+)
+
+func TestReady(t *testing.T) {
+	stopping := make(chan struct{})
+	svc := New(stopping, 20*time.Millisecond, 0, map[string]Check{
+		"fine": func(context.Context) error { return nil },
+	}, slog.Default())
+
+	if report := svc.Ready(context.Background()); report.Status != StatusOK || report.Checks["fine"].Status != StatusOK {
+		t.Errorf("expected to be ready, got %+v", report)
+	}
+
+	close(stopping)
+	if report := svc.Ready(context.Background()); report.Status != StatusFailing || !report.Stopping {
+		t.Errorf("expected not to be ready while stopping, got %+v", report)
+	}
+}
+
+func TestReadyFailing(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	svc := New(make(chan struct{}), 20*time.Millisecond, 0, map[string]Check{
+		"fine":   func(context.Context) error { return nil },
+		"broken": func(context.Context) error { return errors.New("broken") },
+		"slow":   func(context.Context) error { <-block; return nil },
+	}, slog.Default())
+
+	report := svc.Ready(context.Background())
+	if report.Status != StatusFailing || report.Stopping {
+		t.Errorf("expected to be failing, got %+v", report)
+	}
+	for name, expected := range map[string]CheckResult{
+		"fine":   {Status: StatusOK},
+		"broken": {Status: StatusFailing, Error: "broken"},
+		"slow":   {Status: StatusFailing, Error: ErrTimeout.Error()},
+	} {
+		if result := report.Checks[name]; result.Status != expected.Status || result.Error != expected.Error {
+			t.Errorf("expected %s to be %+v, got %+v", name, expected, result)
+		}
+	}
+	if slow := report.Checks["slow"].Latency; slow < 20 {
+		t.Errorf("expected slow to take at least the timeout, took %vms", slow)
+	}
+
+	// why a check failed isn't public.
+	if body, _ := json.Marshal(report); strings.Contains(string(body), "error") {
+		t.Errorf("expected errors to be left out, got %s", body)
+	}
+}
+
+func TestReadyCached(t *testing.T) {
+	stopping := make(chan struct{})
+	var runs atomic.Int32
+	svc := New(stopping, 20*time.Millisecond, 50*time.Millisecond, map[string]Check{
+		"counted": func(context.Context) error { runs.Add(1); return nil },
+	}, slog.Default())
+
+	// a canceled request doesn't fail the checks it shares.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := svc.Ready(ctx); report.Status != StatusOK {
+		t.Errorf("expected to be ready, got %+v", report)
+	}
+	svc.Ready(context.Background())
+	if n := runs.Load(); n != 1 {
+		t.Errorf("expected the checks to be run once, ran %d times", n)
+	}
+
+	close(stopping)
+	if report := svc.Ready(context.Background()); !report.Stopping {
+		t.Errorf("expected stopping not to be cached, got %+v", report)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	svc.Ready(context.Background())
+	if n := runs.Load(); n != 2 {
+		t.Errorf("expected the checks to be run again once they're old, ran %d times", n)
+	}
+}
@@ -6,19 +6,29 @@ import (
 	"fmt"
 
 	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrNotFound = errors.New("messages: message not found")
+
+var tracer = otel.Tracer("github.com/Gardego5/garrettdavis.dev/service/messages")
+
 type Service struct {
 	db              *sqlx.DB
+	metrics         *metrics.Service
 	listMessageAsc  *sqlx.NamedStmt
 	listMessageDesc *sqlx.NamedStmt
 	deleteMessage   *sqlx.NamedStmt
 	createMessage   *sqlx.NamedStmt
 }
 
-func New(db *sqlx.DB) (*Service, error) {
-	svc, err := Service{db: db}, error(nil)
+func New(db *sqlx.DB, metrics *metrics.Service) (*Service, error) {
+	svc, err := Service{db: db, metrics: metrics}, error(nil)
 
 	const LIST_TEMPLATE = `
 SELECT ROW_NUMBER() OVER (ORDER BY created_at %s) as id,
@@ -51,13 +61,26 @@ INSERT INTO contact_messages
      , email
      , message
      , created_at)
-VALUES (:name, :email, :message, :created_at)`); err != nil {
+VALUES (:name, :email, :message, :created_at)
+RETURNING id`); err != nil {
 		return nil, err
 	}
 
 	return &svc, nil
 }
 
+// statement traces and times a statement, call the func it returns with the
+// statement's error once it's done.
+func (svc *Service) statement(ctx context.Context, name string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "messages."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameSQLite, semconv.DBCollectionName("contact_messages")))
+	observe := svc.metrics.Statement("messages." + name)
+	return ctx, func(err error) {
+		observe(err)
+		tracing.End(span, err)
+	}
+}
+
 type (
 	ListMessageInputSort string
 	ListMessageInput     struct {
@@ -73,9 +96,13 @@ const ListMessageInputSortDESC ListMessageInputSort = "DESC"
 func (svc *Service) ListMessages(ctx context.Context, input *ListMessageInput) (out []model.ContactMessage, err error) {
 	switch input.Sort {
 	case ListMessageInputSortASC:
+		ctx, done := svc.statement(ctx, "list_asc")
 		err = svc.listMessageAsc.SelectContext(ctx, &out, input)
+		done(err)
 	case ListMessageInputSortDESC:
+		ctx, done := svc.statement(ctx, "list_desc")
 		err = svc.listMessageDesc.SelectContext(ctx, &out, input)
+		done(err)
 	}
 	return
 }
@@ -85,17 +112,32 @@ type DeleteMessageInput struct {
 }
 
 func (svc *Service) DeleteMessage(ctx context.Context, input *DeleteMessageInput) error {
-	_, err := svc.deleteMessage.ExecContext(ctx, input)
-	return err
+	ctx, done := svc.statement(ctx, "delete")
+	res, err := svc.deleteMessage.ExecContext(ctx, input)
+	done(err)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
+// CreateMessage stores input, and sets its id.
 func (svc *Service) CreateMessage(ctx context.Context, input *model.ContactMessage) error {
-	_, err := svc.createMessage.ExecContext(ctx, input)
+	ctx, done := svc.statement(ctx, "create")
+	err := svc.createMessage.GetContext(ctx, &input.ID, input)
+	done(err)
 	return err
 }
 
 func (svc *Service) CountMessages(ctx context.Context) (count int, err error) {
+	ctx, done := svc.statement(ctx, "count")
 	err = svc.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM contact_messages")
+	done(err)
 	return
 }
 
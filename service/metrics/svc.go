@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Service collects the metrics of the server, and exposes them to
+// prometheus.
+type Service struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	responseSize  *prometheus.HistogramVec
+	inFlight      prometheus.Gauge
+	cacheLookups  *prometheus.CounterVec
+	statements    *prometheus.HistogramVec
+	enforceResult *prometheus.CounterVec
+	panics        prometheus.Counter
+}
+
+func New() *Service {
+	svc := &Service{registry: prometheus.NewRegistry()}
+
+	// requests are labelled by the pattern they matched, rather than their
+	// path, so that every post isn't its own series.
+	svc.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requests answered, by the pattern they matched and their status.",
+	}, []string{"method", "pattern", "status"})
+	svc.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "How long requests took to answer, by the pattern they matched.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "pattern"})
+	svc.responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "How large response bodies were, by the pattern they matched.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"method", "pattern"})
+	svc.inFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Requests being answered.",
+	})
+	svc.cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Cache lookups, by cache and whether they hit.",
+	}, []string{"cache", "result"})
+	svc.statements = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_statement_duration_seconds",
+		Help:    "How long database statements took, by statement and whether they failed.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"statement", "result"})
+	svc.enforceResult = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "casbin_enforce_total",
+		Help: "Authorization decisions, by whether they allowed the request.",
+	}, []string{"result"})
+	svc.panics = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Requests whose handler panicked.",
+	})
+
+	svc.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		svc.requests, svc.duration, svc.responseSize, svc.inFlight,
+		svc.cacheLookups, svc.statements, svc.enforceResult, svc.panics,
+	)
+	svc.handler = promhttp.HandlerFor(svc.registry, promhttp.HandlerOpts{})
+	return svc
+}
+
+// ServeHTTP sends every metric in the prometheus exposition format.
+func (svc *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	svc.handler.ServeHTTP(w, r)
+}
+
+// Request records that a request was started, and returns a func to call
+// with how it was answered.
+func (svc *Service) Request() func(method, pattern string, status, size int) {
+	start := time.Now()
+	svc.inFlight.Inc()
+	return func(method, pattern string, status, size int) {
+		svc.inFlight.Dec()
+		svc.requests.WithLabelValues(method, pattern, strconv.Itoa(status)).Inc()
+		svc.duration.WithLabelValues(method, pattern).Observe(time.Since(start).Seconds())
+		svc.responseSize.WithLabelValues(method, pattern).Observe(float64(size))
+	}
+}
+
+// CacheLookup records how a lookup in the cache with prefix went.
+func (svc *Service) CacheLookup(prefix string, lookup bimarshal.Lookup) {
+	svc.cacheLookups.WithLabelValues(prefix, string(lookup)).Inc()
+}
+
+// Statement records that statement was started, and returns a func to call
+// with its error once it's done.
+func (svc *Service) Statement(statement string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		svc.statements.WithLabelValues(statement, result).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Enforced records an authorization decision.
+func (svc *Service) Enforced(allowed bool) {
+	result := "deny"
+	if allowed {
+		result = "allow"
+	}
+	svc.enforceResult.WithLabelValues(result).Inc()
+}
+
+// Report records that a handler panicked. It's logged elsewhere, only the
+// count is kept.
+func (svc *Service) Report(ctx context.Context, err error, stack []byte) {
+	svc.panics.Inc()
+}
@@ -5,12 +5,18 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/Gardego5/garrettdavis.dev/service/object")
+
 type Service struct {
 	presignClient *s3.PresignClient
 	logger        *slog.Logger
@@ -40,11 +46,17 @@ func New(
 	return &Service{presignClient: ps, logger: logger, bucket: bucket}, nil
 }
 
+func (svc *Service) start(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "object."+op, trace.WithAttributes(
+		attribute.String("object.bucket", svc.bucket), attribute.String("object.key", key)))
+}
+
 // GetObject makes a presigned request that can be used to get an object from a bucket.
 func (svc *Service) GetObject(
 	ctx context.Context, key string, expireSecs int64,
 ) (*v4.PresignedHTTPRequest, error) {
-	request, err := svc.presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+	ctx, span := svc.start(ctx, "GetObject", key)
+	request, err := svc.presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(svc.bucket),
 		Key:    aws.String(key),
 	}, func(opts *s3.PresignOptions) {
@@ -54,6 +66,7 @@ func (svc *Service) GetObject(
 		svc.logger.Error("failed to create presigned GET",
 			"bucket", svc.bucket, "key", key, "error", err)
 	}
+	tracing.End(span, err)
 	return request, err
 }
 
@@ -61,6 +74,7 @@ func (svc *Service) GetObject(
 func (svc *Service) PutObject(
 	ctx context.Context, key string, expireSecs int64,
 ) (*v4.PresignedHTTPRequest, error) {
+	ctx, span := svc.start(ctx, "PutObject", key)
 	request, err := svc.presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
 		Bucket: &svc.bucket, Key: &key,
 	}, func(opts *s3.PresignOptions) {
@@ -70,6 +84,7 @@ func (svc *Service) PutObject(
 		svc.logger.Error("failed to create presigned PUT",
 			"bucket", svc.bucket, "key", key, "error", err)
 	}
+	tracing.End(span, err)
 	return request, err
 }
 
@@ -77,6 +92,7 @@ func (svc *Service) PutObject(
 func (svc *Service) DeleteObject(
 	ctx context.Context, key string, expireSecs int64,
 ) (*v4.PresignedHTTPRequest, error) {
+	ctx, span := svc.start(ctx, "DeleteObject", key)
 	request, err := svc.presignClient.PresignDeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(svc.bucket),
 		Key:    aws.String(key),
@@ -87,5 +103,6 @@ func (svc *Service) DeleteObject(
 		svc.logger.Error("failed to create presigned DELETE",
 			"bucket", svc.bucket, "key", key, "error", err)
 	}
+	tracing.End(span, err)
 	return request, err
 }
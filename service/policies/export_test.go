@@ -0,0 +1,13 @@
+package policies
+
+import (
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// NewWithoutDB is a Service that can only evaluate policies, since changes
+// can't be recorded.
+func NewWithoutDB(enforcer *casbin.SyncedEnforcer) *Service {
+	return &Service{enforcer: enforcer, now: time.Now}
+}
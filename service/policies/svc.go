@@ -0,0 +1,328 @@
+package policies
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/casbin/casbin/v2"
+	casbinmodel "github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/util"
+	"github.com/casbin/govaluate"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrExists      = errors.New("policies: policy already exists")
+	ErrNotFound    = errors.New("policies: policy not found")
+	ErrInvalid     = errors.New("policies: invalid subject rule")
+	ErrInvalidRole = errors.New("policies: invalid role assignment")
+)
+
+// Service manages the p rules of the enforcer, recording who changed what,
+// and the g rules that give roles to users and groups.
+type Service struct {
+	db           *sqlx.DB
+	enforcer     *casbin.SyncedEnforcer
+	createChange *sqlx.NamedStmt
+	listChanges  *sqlx.NamedStmt
+	now          func() time.Time
+}
+
+// RolePrefix namespaces roles in g rules, so they can't be confused with the
+// users and groups they're given to.
+const RolePrefix = "role:"
+
+// functions are the ones casbin makes available to eval(p.sub_rule).
+var functions = func() map[string]govaluate.ExpressionFunction {
+	fm := casbinmodel.LoadFunctionMap()
+	fm.AddFunction("hasRole", hasRole(nil))
+	return fm.GetFunctions()
+}()
+
+// HasRole is the hasRole(r.sub, role) function of subject rules. It's true
+// when the subject, or any of its groups, is given role by the g rules of
+// enforcer, directly or through another role. Roles are named without
+// RolePrefix in subject rules, so hasRole(r.sub, "admin") is true for
+//
+//	g, Gardego5, role:admin
+//	g, @some-org/some-team, role:admin
+func HasRole(enforcer *casbin.SyncedEnforcer) govaluate.ExpressionFunction {
+	if enforcer == nil {
+		return hasRole(nil)
+	}
+	return hasRole(enforcer.Enforcer)
+}
+
+func hasRole(enforcer *casbin.Enforcer) govaluate.ExpressionFunction {
+	return func(args ...any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("hasRole: expected 2 arguments, got %d", len(args))
+		}
+		sub, ok := args[0].(model.Subject)
+		if !ok {
+			return nil, fmt.Errorf("hasRole: expected r.sub as the first argument, got %T", args[0])
+		}
+		role, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("hasRole: expected a role name as the second argument, got %T", args[1])
+		}
+
+		if enforcer == nil {
+			return false, nil
+		}
+
+		// this is called while Enforce holds the lock of the enforcer, so
+		// it mustn't take it again, which GetRoleManager doesn't.
+		rm := enforcer.GetRoleManager()
+		for _, name := range append([]string{sub.User}, sub.Groups...) {
+			if name == "" {
+				continue
+			} else if has, err := rm.HasLink(name, RolePrefix+role); err != nil || has {
+				return has, err
+			}
+		}
+		return false, nil
+	}
+}
+
+func New(db *sqlx.DB, enforcer *casbin.SyncedEnforcer) (*Service, error) {
+	svc, err := Service{db: db, enforcer: enforcer, now: time.Now}, error(nil)
+
+	if svc.createChange, err = svc.db.PrepareNamed(`
+INSERT INTO policy_changes
+     ( actor
+     , action
+     , old_rule
+     , new_rule
+     , role
+     , created_at)
+VALUES (:actor, :action, :old_rule, :new_rule, :role, :created_at)`); err != nil {
+		return nil, err
+	}
+
+	if svc.listChanges, err = svc.db.PrepareNamed(`
+SELECT *
+  FROM policy_changes
+ ORDER BY id DESC
+ LIMIT :limit`); err != nil {
+		return nil, err
+	}
+
+	return &svc, nil
+}
+
+// List returns every policy, in the order the enforcer evaluates them.
+func (s *Service) List() ([]model.Policy, error) {
+	rules, err := s.enforcer.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]model.Policy, len(rules))
+	for i, rule := range rules {
+		policies[i] = model.PolicyFromRule(rule)
+	}
+	return policies, nil
+}
+
+// Validate checks that the subject rule of policy is an expression the
+// enforcer can evaluate. A rule that doesn't evaluate to a boolean breaks
+// every request checked against it, so it's evaluated against an anonymous
+// subject.
+func Validate(policy model.Policy) error {
+	expr, err := govaluate.NewEvaluableExpressionWithFunctions(util.EscapeAssertion(policy.SubRule), functions)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	result, err := expr.Evaluate(map[string]any{"r_sub": model.Subject{}, "r_obj": "", "r_act": ""})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	} else if _, ok := result.(bool); !ok {
+		return fmt.Errorf("%w: evaluates to %T, not a boolean", ErrInvalid, result)
+	}
+	return nil
+}
+
+func (s *Service) Add(ctx context.Context, actor string, policy model.Policy) error {
+	if err := Validate(policy); err != nil {
+		return err
+	}
+
+	// the enforcer reports adding a policy it already has as a success.
+	if has, err := s.enforcer.HasPolicy(policy.Rule()); err != nil {
+		return err
+	} else if has {
+		return ErrExists
+	}
+
+	if _, err := s.enforcer.AddPolicy(policy.Rule()); err != nil {
+		return err
+	}
+
+	return s.record(ctx, actor, model.PolicyActionAdd, nil, &policy)
+}
+
+func (s *Service) Update(ctx context.Context, actor string, old, policy model.Policy) error {
+	if err := Validate(policy); err != nil {
+		return err
+	}
+
+	if has, err := s.enforcer.HasPolicy(policy.Rule()); err != nil {
+		return err
+	} else if has {
+		return ErrExists
+	}
+
+	if ok, err := s.enforcer.UpdatePolicy(old.Rule(), policy.Rule()); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+
+	return s.record(ctx, actor, model.PolicyActionUpdate, &old, &policy)
+}
+
+func (s *Service) Remove(ctx context.Context, actor string, policy model.Policy) error {
+	if ok, err := s.enforcer.RemovePolicy(policy.Rule()); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+
+	return s.record(ctx, actor, model.PolicyActionRemove, &policy, nil)
+}
+
+// Test checks whether sub would be allowed to act on obj, returning the
+// policy that allowed it. It's evaluated against a copy of the policy, so
+// trying things out isn't logged, counted or audited as a real decision.
+func (s *Service) Test(sub model.Subject, obj, act string) (bool, *model.Policy, error) {
+	s.enforcer.GetLock().RLock()
+	m := s.enforcer.GetModel().Copy()
+	s.enforcer.GetLock().RUnlock()
+
+	enforcer, err := casbin.NewEnforcer(m)
+	if err != nil {
+		return false, nil, err
+	}
+	enforcer.AddFunction("hasRole", hasRole(enforcer))
+	if err = enforcer.BuildRoleLinks(); err != nil {
+		return false, nil, err
+	}
+
+	ok, explain, err := enforcer.EnforceEx(sub, obj, act)
+	if err != nil || len(explain) == 0 {
+		return ok, nil, err
+	}
+	policy := model.PolicyFromRule(explain)
+	return ok, &policy, nil
+}
+
+// Roles returns every role given to a user or group, ordered by who it's
+// given to. Roles given to other roles aren't included.
+func (s *Service) Roles() ([]model.RoleAssignment, error) {
+	rules, err := s.enforcer.GetGroupingPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]model.RoleAssignment, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule) < 2 || strings.HasPrefix(rule[0], RolePrefix) {
+			continue
+		}
+		roles = append(roles, model.RoleAssignment{Subject: rule[0], Role: strings.TrimPrefix(rule[1], RolePrefix)})
+	}
+	slices.SortFunc(roles, func(a, b model.RoleAssignment) int {
+		return cmp.Or(strings.Compare(a.Subject, b.Subject), strings.Compare(a.Role, b.Role))
+	})
+	return roles, nil
+}
+
+// ValidateRole checks that role is given to a user or a group, rather than
+// another role, and that neither has whitespace in its name.
+func ValidateRole(role model.RoleAssignment) error {
+	switch {
+	case role.Subject == "" || role.Role == "":
+		return fmt.Errorf("%w: a role and who it's given to are required", ErrInvalidRole)
+	case strings.ContainsFunc(role.Subject+role.Role, unicode.IsSpace):
+		return fmt.Errorf("%w: names can't have spaces", ErrInvalidRole)
+	case strings.HasPrefix(role.Subject, RolePrefix), strings.HasPrefix(role.Role, RolePrefix):
+		return fmt.Errorf("%w: roles are named without %q, and given to users or groups", ErrInvalidRole, RolePrefix)
+	}
+	return nil
+}
+
+func roleRule(role model.RoleAssignment) []string {
+	return []string{role.Subject, RolePrefix + role.Role}
+}
+
+// Assign gives role to its subject.
+func (s *Service) Assign(ctx context.Context, actor string, role model.RoleAssignment) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+
+	if has, err := s.enforcer.HasGroupingPolicy(roleRule(role)); err != nil {
+		return err
+	} else if has {
+		return ErrExists
+	}
+
+	if _, err := s.enforcer.AddGroupingPolicy(roleRule(role)); err != nil {
+		return err
+	}
+
+	return s.recordRole(ctx, actor, model.PolicyActionAssign, role)
+}
+
+// Unassign takes role away from its subject.
+func (s *Service) Unassign(ctx context.Context, actor string, role model.RoleAssignment) error {
+	if ok, err := s.enforcer.RemoveGroupingPolicy(roleRule(role)); err != nil {
+		return err
+	} else if !ok {
+		return ErrNotFound
+	}
+
+	return s.recordRole(ctx, actor, model.PolicyActionUnassign, role)
+}
+
+// Changes returns the most recent changes, newest first.
+func (s *Service) Changes(ctx context.Context, limit int) (out []model.PolicyChange, err error) {
+	err = s.listChanges.SelectContext(ctx, &out, map[string]any{"limit": limit})
+	return
+}
+
+func (s *Service) record(ctx context.Context, actor string, action model.PolicyAction, old, policy *model.Policy) error {
+	change := model.PolicyChange{
+		Actor:     actor,
+		Action:    action,
+		CreatedAt: model.Time{Time: s.now()},
+	}
+	if old != nil {
+		change.OldRule = model.NullPolicy{Policy: *old, Valid: true}
+	}
+	if policy != nil {
+		change.NewRule = model.NullPolicy{Policy: *policy, Valid: true}
+	}
+
+	_, err := s.createChange.ExecContext(ctx, change)
+	return err
+}
+
+func (s *Service) recordRole(ctx context.Context, actor string, action model.PolicyAction, role model.RoleAssignment) error {
+	_, err := s.createChange.ExecContext(ctx, model.PolicyChange{
+		Actor:     actor,
+		Action:    action,
+		Role:      model.NullRoleAssignment{RoleAssignment: role, Valid: true},
+		CreatedAt: model.Time{Time: s.now()},
+	})
+	return err
+}
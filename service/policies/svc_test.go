@@ -0,0 +1,233 @@
+package policies_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/resource/initialize"
+	"github.com/Gardego5/garrettdavis.dev/service/metrics"
+	. "github.com/Gardego5/garrettdavis.dev/service/policies"
+	"github.com/Gardego5/garrettdavis.dev/utils/testdb"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/log"
+	casbinmodel "github.com/casbin/casbin/v2/model"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		rule string
+		ok   bool
+	}{
+		{`r.sub.User == "Gardego5"`, true},
+		{`r.sub.Provider == "gitlab" && r.sub.User != ""`, true},
+		{`keyMatch(r.sub.User, "gitlab/*")`, true},
+		{`true`, true},
+		{`r.sub.User ==`, false},
+		{`r.sub.User`, false},
+		{`unknownFunc(r.sub.User)`, false},
+		{`hasRole(r.sub, "admin")`, true},
+		{`hasRole("admin")`, false},
+	} {
+		err := Validate(model.Policy{SubRule: tc.rule, Obj: "/admin/*", Act: "GET"})
+		if tc.ok && err != nil {
+			t.Errorf("%q: expected valid, got %v", tc.rule, err)
+		} else if !tc.ok && !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: expected ErrInvalid, got %v", tc.rule, err)
+		}
+	}
+}
+
+// decisions counts the decisions an enforcer logs.
+type decisions struct {
+	log.DefaultLogger
+	n int
+}
+
+func (d *decisions) LogEnforce(string, []any, bool, [][]string) { d.n++ }
+
+func newEnforcer(t *testing.T) (*casbin.SyncedEnforcer, *decisions) {
+	m, err := casbinmodel.NewModelFromString(initialize.ModelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction("hasRole", HasRole(enforcer))
+	decisions := &decisions{}
+	decisions.EnableLog(true)
+	enforcer.SetLogger(decisions)
+
+	enforcer.AddPolicies([][]string{
+		{`hasRole(r.sub, "admin")`, "/admin/*", "GET"},
+		{`hasRole(r.sub, "editor")`, "/posts/*", "GET"},
+		{`r.sub.User == "Gardego5"`, "/legacy", "GET"},
+	})
+	enforcer.AddGroupingPolicies([][]string{
+		{"Gardego5", RolePrefix + "admin"},
+		{"@acme/devs", RolePrefix + "editor"},
+		{RolePrefix + "admin", RolePrefix + "editor"},
+	})
+	return enforcer, decisions
+}
+
+func TestHasRole(t *testing.T) {
+	enforcer, _ := newEnforcer(t)
+	for _, tc := range []struct {
+		sub     model.Subject
+		obj     string
+		allowed bool
+	}{
+		{model.Subject{User: "Gardego5"}, "/admin/messages", true},
+		{model.Subject{User: "Gardego5"}, "/posts/1", true},
+		{model.Subject{User: "Gardego5"}, "/legacy", true},
+		{model.Subject{User: "someone", Groups: []string{"@acme", "@acme/devs"}}, "/posts/1", true},
+		{model.Subject{User: "someone", Groups: []string{"@acme", "@acme/devs"}}, "/admin/messages", false},
+		{model.Subject{User: "someone"}, "/posts/1", false},
+		// roles aren't users.
+		{model.Subject{User: "admin"}, "/admin/messages", false},
+	} {
+		if allowed, err := enforcer.Enforce(tc.sub, tc.obj, "GET"); err != nil {
+			t.Fatal(err)
+		} else if allowed != tc.allowed {
+			t.Errorf("%+v %s: expected %v, got %v", tc.sub, tc.obj, tc.allowed, allowed)
+		}
+	}
+}
+
+func TestTest(t *testing.T) {
+	enforcer, decisions := newEnforcer(t)
+	svc := NewWithoutDB(enforcer)
+
+	ok, p, err := svc.Test(model.Subject{User: "Gardego5"}, "/admin/messages", "GET")
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok || p == nil || p.SubRule != `hasRole(r.sub, "admin")` {
+		t.Errorf("expected to be allowed by the admin policy, got %v by %+v", ok, p)
+	}
+	if ok, _, _ = svc.Test(model.Subject{User: "someone"}, "/admin/messages", "GET"); ok {
+		t.Error("expected someone to be denied")
+	}
+
+	// tests aren't real decisions.
+	if decisions.n != 0 {
+		t.Errorf("expected tests not to be logged, %d were", decisions.n)
+	}
+	if enforcer.Enforce(model.Subject{User: "someone"}, "/admin/messages", "GET"); decisions.n != 1 {
+		t.Errorf("expected real decisions to be logged, %d were", decisions.n)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	enforcer, _ := newEnforcer(t)
+	svc, err := New(testdb.Open(t), enforcer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	someone := model.Subject{User: "someone"}
+
+	if err := svc.Assign(ctx, "Gardego5", model.RoleAssignment{Subject: "someone", Role: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := enforcer.Enforce(someone, "/admin/messages", "GET"); !ok {
+		t.Error("expected an assigned role to allow someone")
+	}
+	if err := svc.Assign(ctx, "Gardego5", model.RoleAssignment{Subject: "someone", Role: "admin"}); !errors.Is(err, ErrExists) {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+
+	// roles given to other roles aren't listed.
+	roles, err := svc.Roles()
+	if err != nil {
+		t.Fatal(err)
+	} else if want := []model.RoleAssignment{
+		{Subject: "@acme/devs", Role: "editor"},
+		{Subject: "Gardego5", Role: "admin"},
+		{Subject: "someone", Role: "admin"},
+	}; !slices.Equal(roles, want) {
+		t.Errorf("expected %v, got %v", want, roles)
+	}
+
+	if err := svc.Unassign(ctx, "Gardego5", model.RoleAssignment{Subject: "someone", Role: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := enforcer.Enforce(someone, "/admin/messages", "GET"); ok {
+		t.Error("expected an unassigned role not to allow someone")
+	}
+	if err := svc.Unassign(ctx, "Gardego5", model.RoleAssignment{Subject: "someone", Role: "admin"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	for _, a := range []model.RoleAssignment{
+		{Subject: "", Role: "admin"},
+		{Subject: "someone", Role: ""},
+		{Subject: "some one", Role: "admin"},
+		{Subject: RolePrefix + "editor", Role: "admin"},
+		{Subject: "someone", Role: RolePrefix + "admin"},
+	} {
+		if err := svc.Assign(ctx, "Gardego5", a); !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("%+v: expected ErrInvalidRole, got %v", a, err)
+		}
+	}
+
+	// only the changes that happened are recorded, newest first.
+	changes, err := svc.Changes(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	for i, action := range []model.PolicyAction{model.PolicyActionUnassign, model.PolicyActionAssign} {
+		c := changes[i]
+		if c.Actor != "Gardego5" || c.Action != action || !c.Role.Valid ||
+			c.Role.RoleAssignment != (model.RoleAssignment{Subject: "someone", Role: "admin"}) {
+			t.Errorf("expected Gardego5 to %s admin to someone, got %+v", action, c)
+		}
+	}
+}
+
+func TestSeededPolicies(t *testing.T) {
+	enforcer, err := initialize.Enforcer(testdb.Open(t), slog.New(slog.NewTextHandler(io.Discard, nil)), metrics.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := model.Subject{Provider: model.ProviderGithub, User: "Gardego5"}
+	someone := model.Subject{Provider: model.ProviderGithub, User: "someone"}
+	scraper := model.Subject{Provider: model.ProviderGithub, User: "scraper"}
+	if _, err := enforcer.AddGroupingPolicy(scraper.User, RolePrefix+"metrics"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		sub      model.Subject
+		obj, act string
+		allowed  bool
+	}{
+		{admin, "/admin/messages", "GET", true},
+		{admin, "/admin/policies", "PUT", true},
+		{admin, "/api/v1/messages", "GET", true},
+		{admin, "/api/v1/messages/1", "DELETE", true},
+		{someone, "/admin/messages", "GET", false},
+		{someone, "/api/v1/messages", "GET", false},
+		{admin, "/metrics", "GET", true},
+		{scraper, "/metrics", "GET", true},
+		{scraper, "/admin/tokens", "POST", true},
+		{scraper, "/admin/tokens/1", "DELETE", true},
+		{scraper, "/admin/messages", "GET", false},
+		{scraper, "/api/v1/messages", "GET", false},
+		{someone, "/metrics", "GET", false},
+	} {
+		if allowed, err := enforcer.Enforce(tc.sub, tc.obj, tc.act); err != nil {
+			t.Fatal(err)
+		} else if allowed != tc.allowed {
+			t.Errorf("%s %s %s: expected %v, got %v", tc.sub.User, tc.act, tc.obj, tc.allowed, allowed)
+		}
+	}
+}
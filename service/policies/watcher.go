@@ -0,0 +1,221 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	watcherChannel = "policies:changed"
+	watcherVersion = "policies:version"
+
+	watcherMinBackoff = time.Second
+	watcherMaxBackoff = time.Minute
+)
+
+// Watcher keeps the policy of every machine in sync. Changes made through
+// the enforcer bump a version in redis, and publish it, so every machine
+// reloads its policy immediately. The version is also polled, in case a
+// message is missed while reconnecting.
+//
+// Failed reloads keep the last good policy, and are retried with backoff.
+type Watcher struct {
+	rdb      *redis.Client
+	enforcer *casbin.SyncedEnforcer
+	logger   *slog.Logger
+	poll     time.Duration
+	updates  chan struct{}
+
+	mu     sync.Mutex
+	status WatcherStatus
+}
+
+var _ persist.Watcher = (*Watcher)(nil)
+
+// WatcherStatus is the version of the policy a machine has loaded, and how
+// its last reload went.
+type WatcherStatus struct {
+	Version    int64     `json:"version"`
+	ReloadedAt time.Time `json:"reloaded_at"`
+	Error      string    `json:"error,omitempty"`
+	FailedAt   time.Time `json:"failed_at,omitempty"`
+	// Failures is how many attempts have failed in a row.
+	Failures int `json:"failures"`
+}
+
+// NewWatcher creates a watcher, and sets it as the watcher of enforcer. It
+// does nothing until it's started with Run.
+func NewWatcher(rdb *redis.Client, enforcer *casbin.SyncedEnforcer, logger *slog.Logger) (*Watcher, error) {
+	w := &Watcher{
+		rdb:      rdb,
+		enforcer: enforcer,
+		logger:   logger.With("scope", "policies.Watcher"),
+		poll:     time.Minute,
+		updates:  make(chan struct{}, 1),
+	}
+	return w, enforcer.SetWatcher(w)
+}
+
+// SetUpdateCallback is a no-op, the watcher reloads the enforcer itself so
+// that it can retry.
+func (w *Watcher) SetUpdateCallback(func(string)) error { return nil }
+
+// Update is called by the enforcer after its policy is changed. Publishing
+// happens in Run, so a change is never lost because redis is unavailable.
+func (w *Watcher) Update() error {
+	select {
+	case w.updates <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (w *Watcher) Close() {}
+
+// Status is the version of the policy that's loaded, and how the last
+// reload went.
+func (w *Watcher) Status() WatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run reloads the policy whenever it changes, until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	sub := w.rdb.Subscribe(ctx, watcherChannel)
+	defer sub.Close()
+	messages := sub.Channel()
+
+	poll := time.NewTicker(w.poll)
+	defer poll.Stop()
+
+	// the policy may have changed between the enforcer loading it, and
+	// subscribing, so the first reload happens right away.
+	reload, publish := true, false
+	backoff := watcherMinBackoff
+	var retry <-chan time.Time
+
+	for {
+		if (reload || publish) && retry == nil {
+			var err error
+			if publish {
+				var missed bool
+				if missed, err = w.publish(ctx); err == nil {
+					publish, reload = false, reload || missed
+				}
+			}
+			if err == nil && reload {
+				if err = w.reload(ctx); err == nil {
+					reload = false
+				}
+			}
+
+			if err != nil {
+				w.failed(err, backoff)
+				retry = time.After(backoff)
+				backoff = min(backoff*2, watcherMaxBackoff)
+			} else {
+				backoff = watcherMinBackoff
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-w.updates:
+			publish = true
+
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if version, err := strconv.ParseInt(msg.Payload, 10, 64); err != nil {
+				w.logger.WarnContext(ctx, "ignoring malformed policy change", "payload", msg.Payload)
+			} else if version > w.Status().Version {
+				reload = true
+			}
+
+		case <-poll.C:
+			if version, err := w.version(ctx); err != nil {
+				w.logger.WarnContext(ctx, "error polling policy version", "error", err)
+			} else if version != w.Status().Version {
+				reload = true
+			}
+
+		case <-retry:
+			retry = nil
+		}
+	}
+}
+
+// version is the latest version of the policy, which is 0 until the policy
+// is first changed.
+func (w *Watcher) version(ctx context.Context) (int64, error) {
+	version, err := w.rdb.Get(ctx, watcherVersion).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// reload loads the policy, and the version it's at. The version is read
+// first, so a change that happens during the reload is reloaded again.
+func (w *Watcher) reload(ctx context.Context) error {
+	version, err := w.version(ctx)
+	if err != nil {
+		return err
+	}
+	if err = w.enforcer.LoadPolicy(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	previous := w.status.Version
+	w.status = WatcherStatus{Version: version, ReloadedAt: time.Now()}
+	w.mu.Unlock()
+
+	w.logger.InfoContext(ctx, "Policy reloaded", "version", version, "previous", previous)
+	return nil
+}
+
+// publish tells every machine about a change made here. This machine
+// already has the change, unless it also missed some other change, in which
+// case it needs to reload.
+func (w *Watcher) publish(ctx context.Context) (reload bool, err error) {
+	version, err := w.rdb.Incr(ctx, watcherVersion).Result()
+	if err != nil {
+		return false, err
+	}
+	if err = w.rdb.Publish(ctx, watcherChannel, version).Err(); err != nil {
+		return false, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Error, w.status.FailedAt, w.status.Failures = "", time.Time{}, 0
+	if version != w.status.Version+1 {
+		return true, nil
+	}
+	w.status.Version = version
+	return false, nil
+}
+
+func (w *Watcher) failed(err error, retry time.Duration) {
+	w.mu.Lock()
+	w.status.Error = err.Error()
+	w.status.FailedAt = time.Now()
+	w.status.Failures++
+	failures := w.status.Failures
+	w.mu.Unlock()
+
+	w.logger.Error("Error syncing policy, keeping the last good one", "error", err, "failures", failures, "retry", retry)
+}
@@ -55,9 +55,9 @@ func (s *Service) Presentations() map[string]Presentation {
 func (s *Service) initialize() {
 	s.presentations = make(map[string]Presentation)
 
-	dirents, err := presentationfs.ReadDir("presentation")
+	dirents, err := presentationfs.ReadDir("data")
 	if err != nil {
-		slog.Error("error reading blog directory", "error", err)
+		slog.Error("error reading presentations directory", "error", err)
 		os.Exit(1)
 	}
 
@@ -72,7 +72,7 @@ func (s *Service) initialize() {
 			continue
 		}
 
-		file, err := presentationfs.ReadFile(fmt.Sprintf("presentation/%s", name))
+		file, err := presentationfs.ReadFile(fmt.Sprintf("data/%s", name))
 		if err != nil {
 			slog.Error("error reading blog file", "error", err)
 			os.Exit(1)
@@ -0,0 +1,265 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/Gardego5/garrettdavis.dev/utils"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/google/go-github/v66/github"
+	"github.com/google/uuid"
+)
+
+// how stale last seen may get before a request writes it back. this keeps
+// every request from writing to the cache.
+const touchInterval = time.Minute
+
+type Service struct {
+	sessions     bimarshal.Cache[model.Session]
+	accessTokens bimarshal.Cache[model.AccessToken]
+	subjects     bimarshal.Cache[model.Subject]
+	users        bimarshal.Cache[github.User]
+	groups       bimarshal.Cache[model.GithubGroups]
+	idle         time.Duration
+	now          func() time.Time
+}
+
+// New creates a session service. Sessions, and the credentials belonging to
+// them, expire after being idle for idle.
+func New(caches bimarshal.RegisteredCaches, idle time.Duration) *Service {
+	return &Service{
+		sessions:     bimarshal.Get[model.Session](caches),
+		accessTokens: bimarshal.Get[model.AccessToken](caches),
+		subjects:     bimarshal.Get[model.Subject](caches),
+		users:        bimarshal.Get[github.User](caches),
+		groups:       bimarshal.Get[model.GithubGroups](caches),
+		idle:         idle,
+		now:          time.Now,
+	}
+}
+
+func (s *Service) IdleTimeout() time.Duration { return s.idle }
+
+func (s *Service) Get(ctx context.Context, id string) (*model.Session, error) {
+	return s.sessions.Get(ctx, id)
+}
+
+func (s *Service) Save(ctx context.Context, session *model.Session) error {
+	return s.sessions.Set(ctx, session.ID, *session, s.idle)
+}
+
+// GetOrCreate loads the session with id, or starts a new anonymous session
+// with that id for r if there isn't one yet.
+func (s *Service) GetOrCreate(ctx context.Context, id string, r *http.Request) (*model.Session, error) {
+	session, err := s.Get(ctx, id)
+	if errors.Is(err, bimarshal.ErrNotFound) {
+		if session, err = s.new(id, r); err != nil {
+			return nil, err
+		}
+		err = s.Save(ctx, session)
+	}
+	return session, err
+}
+
+func (s *Service) new(id string, r *http.Request) (*model.Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	return &model.Session{
+		ID:        id,
+		CreatedAt: now,
+		LastSeen:  now,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		CSRFToken: token,
+	}, nil
+}
+
+// CSRFToken returns the csrf token of the session with id, starting a session
+// for r if there isn't one yet.
+func (s *Service) CSRFToken(ctx context.Context, id string, r *http.Request) (string, error) {
+	session, err := s.GetOrCreate(ctx, id, r)
+	if err != nil {
+		return "", err
+	} else if session.CSRFToken == "" {
+		if session.CSRFToken, err = newToken(); err != nil {
+			return "", err
+		}
+		err = s.Save(ctx, session)
+	}
+	return session.CSRFToken, err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Seen records that session was used by r, sliding the expiry of the session
+// and its credentials. It reports whether anything was written.
+func (s *Service) Seen(ctx context.Context, session *model.Session, r *http.Request) (bool, error) {
+	now := s.now()
+	if now.Sub(session.LastSeen) < touchInterval {
+		return false, nil
+	}
+
+	session.LastSeen = now
+	session.IP = utils.ClientIP(r)
+	session.UserAgent = r.UserAgent()
+	if err := s.Save(ctx, session); err != nil {
+		return false, err
+	}
+	if session.User == "" {
+		return true, nil
+	}
+
+	return true, errors.Join(
+		ignoreNotFound(s.accessTokens.Touch(ctx, session.ID, s.idle)),
+		ignoreNotFound(s.subjects.Touch(ctx, session.ID, s.idle)),
+	)
+}
+
+// Login starts an authenticated session for sub, replacing the session with
+// id. The new session always gets a new id, so an id planted before signing
+// in is useless afterwards.
+func (s *Service) Login(
+	ctx context.Context,
+	r *http.Request,
+	id string,
+	sub model.Subject,
+	token model.AccessToken,
+) (*model.Session, error) {
+	session, err := s.new(uuid.NewString(), r)
+	if err != nil {
+		return nil, err
+	}
+	session.User = sub.User
+
+	if old, err := s.Get(ctx, id); err == nil {
+		session.Flash = old.Flash
+	} else if !errors.Is(err, bimarshal.ErrNotFound) {
+		return nil, err
+	}
+
+	if err := s.Revoke(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := errors.Join(
+		s.Save(ctx, session),
+		s.accessTokens.Set(ctx, session.ID, token, s.idle),
+		s.subjects.Set(ctx, session.ID, sub, s.idle),
+	); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Subject is who the session with id signed in as.
+func (s *Service) Subject(ctx context.Context, id string) (*model.Subject, error) {
+	return s.subjects.Get(ctx, id)
+}
+
+// Token is the access token the session with id signed in with.
+func (s *Service) Token(ctx context.Context, id string) (*model.AccessToken, error) {
+	return s.accessTokens.Get(ctx, id)
+}
+
+// Revoke ends the sessions with ids, removing all the state stored for them
+// from every cache, including what was looked up with their access tokens.
+func (s *Service) Revoke(ctx context.Context, ids ...string) error {
+	tokens := []string{}
+	for _, id := range ids {
+		if token, err := s.Token(ctx, id); err == nil {
+			tokens = append(tokens, token.AccessToken)
+		} else if !errors.Is(err, bimarshal.ErrNotFound) {
+			return err
+		}
+	}
+
+	return errors.Join(
+		s.sessions.Delete(ctx, ids...),
+		s.accessTokens.Delete(ctx, ids...),
+		s.subjects.Delete(ctx, ids...),
+		s.users.Delete(ctx, tokens...),
+		s.groups.Delete(ctx, tokens...),
+	)
+}
+
+// RevokeUser ends every session signed in as user, returning the sessions
+// that were ended.
+func (s *Service) RevokeUser(ctx context.Context, user string) ([]model.Session, error) {
+	if user == "" {
+		return nil, errors.New("session: can't revoke sessions of an anonymous user")
+	}
+
+	sessions, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions = slices.DeleteFunc(sessions, func(session model.Session) bool { return session.User != user })
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	return sessions, s.Revoke(ctx, ids...)
+}
+
+// List returns every active session, most recently seen first.
+func (s *Service) List(ctx context.Context) ([]model.Session, error) {
+	ids, err := s.sessions.Keys(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]model.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := s.Get(ctx, id)
+		if errors.Is(err, bimarshal.ErrNotFound) {
+			continue // expired since listing the keys
+		} else if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	slices.SortFunc(sessions, func(a, b model.Session) int { return b.LastSeen.Compare(a.LastSeen) })
+	return sessions, nil
+}
+
+// AddFlash queues a message to show on the next page session renders.
+func (s *Service) AddFlash(ctx context.Context, session *model.Session, message string) error {
+	session.Flash = append(session.Flash, message)
+	return s.Save(ctx, session)
+}
+
+// Flashes removes and returns the queued flash messages of session.
+func (s *Service) Flashes(ctx context.Context, session *model.Session) ([]string, error) {
+	flash := session.Flash
+	if len(flash) == 0 {
+		return nil, nil
+	}
+	session.Flash = nil
+	return flash, s.Save(ctx, session)
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, bimarshal.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	. "github.com/Gardego5/garrettdavis.dev/service/session"
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/google/go-github/v66/github"
+)
+
+func newService() (*Service, bimarshal.RegisteredCaches) {
+	caches := bimarshal.Caches{
+		"user":          bimarshal.Register[github.User](bimarshal.JSON),
+		"github-groups": bimarshal.Register[model.GithubGroups](bimarshal.MessagePack),
+		"access-token":  bimarshal.Register[model.AccessToken](bimarshal.MessagePack),
+		"session":       bimarshal.Register[model.Session](bimarshal.MessagePack),
+		"subject":       bimarshal.Register[model.Subject](bimarshal.MessagePack),
+	}.Build(bimarshal.NewMemoryStore(100))
+	return New(caches, time.Hour), caches
+}
+
+func TestLoginRotatesSession(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newService()
+	r := httptest.NewRequest("GET", "/auth/callback", nil)
+
+	anonymous, err := svc.GetOrCreate(ctx, "planted", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.AddFlash(ctx, anonymous, "hello")
+
+	session, err := svc.Login(ctx, r, "planted", model.Subject{User: "someone"}, model.AccessToken{AccessToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.ID == "planted" {
+		t.Error("expected a new session id after login")
+	}
+	if _, err := svc.Get(ctx, "planted"); !errors.Is(err, bimarshal.ErrNotFound) {
+		t.Errorf("expected old session to be removed, got %v", err)
+	}
+	if flash, _ := svc.Flashes(ctx, session); len(flash) != 1 || flash[0] != "hello" {
+		t.Errorf("expected flash to carry over, got %v", flash)
+	}
+	if token, err := svc.Token(ctx, session.ID); err != nil || token.AccessToken != "token" {
+		t.Errorf("expected token to be stored for new session, got %v, %v", token, err)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	svc, caches := newService()
+	r := httptest.NewRequest("GET", "/auth/callback", nil)
+	users := bimarshal.Get[github.User](caches)
+
+	var ids []string
+	for _, login := range []string{"someone", "someone", "someone-else"} {
+		session, err := svc.Login(ctx, r, "", model.Subject{User: login}, model.AccessToken{AccessToken: "token-" + login})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users.Set(ctx, "token-"+login, github.User{Login: &login}, time.Hour)
+		ids = append(ids, session.ID)
+	}
+
+	revoked, err := svc.RevokeUser(ctx, "someone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 2 {
+		t.Errorf("expected 2 sessions to be revoked, got %d", len(revoked))
+	}
+
+	for _, id := range ids[:2] {
+		if _, err := svc.Token(ctx, id); !errors.Is(err, bimarshal.ErrNotFound) {
+			t.Errorf("expected token of revoked session to be removed, got %v", err)
+		}
+	}
+	if _, err := users.Get(ctx, "token-someone"); !errors.Is(err, bimarshal.ErrNotFound) {
+		t.Errorf("expected cached user of revoked token to be removed, got %v", err)
+	}
+	if _, err := svc.Token(ctx, ids[2]); err != nil {
+		t.Errorf("expected other user's session to remain, got %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+package tokens
+
+import "time"
+
+// SetNow changes the clock of svc.
+func (svc *Service) SetNow(now func() time.Time) { svc.now = now }
@@ -0,0 +1,189 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// Prefix starts every token, so they're easy to recognize, ie. by secret
+// scanners.
+const Prefix = "gdd_"
+
+var (
+	ErrInvalid  = errors.New("tokens: invalid token")
+	ErrExpired  = errors.New("tokens: token has expired")
+	ErrNotFound = errors.New("tokens: token not found")
+)
+
+type Service struct {
+	db          *sqlx.DB
+	createToken *sqlx.NamedStmt
+	getToken    *sqlx.NamedStmt
+	listTokens  *sqlx.NamedStmt
+	revokeToken *sqlx.NamedStmt
+	touchToken  *sqlx.NamedStmt
+	now         func() time.Time
+}
+
+func New(db *sqlx.DB) (*Service, error) {
+	svc, err := Service{db: db, now: time.Now}, error(nil)
+
+	if svc.createToken, err = svc.db.PrepareNamed(`
+INSERT INTO api_tokens
+     ( name
+     , provider
+     , owner
+     , prefix
+     , hash
+     , scopes
+     , groups
+     , expires_at
+     , created_at)
+VALUES (:name, :provider, :owner, :prefix, :hash, :scopes, :groups, :expires_at, :created_at)
+RETURNING id`); err != nil {
+		return nil, err
+	}
+
+	if svc.getToken, err = svc.db.PrepareNamed(`
+SELECT *
+  FROM api_tokens
+ WHERE hash = :hash
+   AND revoked_at IS NULL`); err != nil {
+		return nil, err
+	}
+
+	if svc.listTokens, err = svc.db.PrepareNamed(`
+SELECT *
+  FROM api_tokens
+ WHERE owner = :owner
+   AND revoked_at IS NULL
+ ORDER BY id DESC`); err != nil {
+		return nil, err
+	}
+
+	if svc.revokeToken, err = svc.db.PrepareNamed(`
+UPDATE api_tokens
+   SET revoked_at = :now
+ WHERE id = :id
+   AND owner = :owner
+   AND revoked_at IS NULL`); err != nil {
+		return nil, err
+	}
+
+	if svc.touchToken, err = svc.db.PrepareNamed(`
+UPDATE api_tokens
+   SET last_used_at = :now
+ WHERE id = :id`); err != nil {
+		return nil, err
+	}
+
+	return &svc, nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create makes a token for owner, with owner's groups, returning the secret
+// that's used to authenticate with it. It can't be recovered later. Tokens
+// with a zero expiry never expire.
+func (svc *Service) Create(
+	ctx context.Context,
+	owner model.Subject,
+	name string,
+	scopes model.Scopes,
+	expires time.Time,
+) (string, *model.APIToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := Prefix + base64.RawURLEncoding.EncodeToString(b)
+
+	token := model.APIToken{
+		Name:      name,
+		Provider:  owner.Provider,
+		Owner:     owner.User,
+		Prefix:    secret[:len(Prefix)+6],
+		Hash:      hash(secret),
+		Scopes:    scopes,
+		Groups:    owner.Groups,
+		ExpiresAt: model.NullTime{Time: model.Time{Time: expires.UTC()}, Valid: !expires.IsZero()},
+		CreatedAt: model.Time{Time: svc.now().UTC()},
+	}
+	if err := svc.createToken.GetContext(ctx, &token.ID, token); err != nil {
+		return "", nil, err
+	}
+
+	return secret, &token, nil
+}
+
+// Authenticate finds the token with secret, which must not have been
+// revoked, or have expired.
+func (svc *Service) Authenticate(ctx context.Context, secret string) (*model.APIToken, error) {
+	if !strings.HasPrefix(secret, Prefix) {
+		return nil, ErrInvalid
+	}
+
+	var token model.APIToken
+	err := svc.getToken.GetContext(ctx, &token, map[string]any{"hash": hash(secret)})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalid
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := svc.now()
+	if token.ExpiresAt.Valid && !now.Before(token.ExpiresAt.Time.Time) {
+		return nil, ErrExpired
+	}
+
+	token.LastUsedAt = model.NullTime{Time: model.Time{Time: now.UTC()}, Valid: true}
+	if _, err = svc.touchToken.ExecContext(ctx, map[string]any{"id": token.ID, "now": token.LastUsedAt}); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// List lists the tokens of owner that haven't been revoked, newest first.
+func (svc *Service) List(ctx context.Context, owner string) (out []model.APIToken, err error) {
+	err = svc.listTokens.SelectContext(ctx, &out, map[string]any{"owner": owner})
+	return
+}
+
+// Revoke revokes the token with id, if it belongs to owner.
+func (svc *Service) Revoke(ctx context.Context, owner string, id int) error {
+	res, err := svc.revokeToken.ExecContext(ctx, map[string]any{
+		"id": id, "owner": owner, "now": model.Time{Time: svc.now().UTC()}})
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (svc *Service) Close() error {
+	return errors.Join(
+		svc.createToken.Close(),
+		svc.getToken.Close(),
+		svc.listTokens.Close(),
+		svc.revokeToken.Close(),
+		svc.touchToken.Close(),
+	)
+}
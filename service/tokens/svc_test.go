@@ -0,0 +1,92 @@
+package tokens_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	. "github.com/Gardego5/garrettdavis.dev/service/tokens"
+	"github.com/Gardego5/garrettdavis.dev/utils/testdb"
+)
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	svc, err := New(testdb.Open(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer svc.Close()
+	now := time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC)
+	svc.SetNow(func() time.Time { return now })
+
+	owner := model.Subject{Provider: model.ProviderGithub, User: "Gardego5", Groups: []string{"@org", "@org/team"}}
+	secret, token, err := svc.Create(ctx, owner, "deploys", model.Scopes{model.TokenScopeRead}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only a hash of the secret is kept.
+	if !strings.HasPrefix(secret, Prefix) || !strings.HasPrefix(secret, token.Prefix) {
+		t.Errorf("expected %q to start with %q and %q", secret, Prefix, token.Prefix)
+	}
+	if token.Hash == "" || strings.Contains(token.Hash, secret[len(Prefix):]) {
+		t.Errorf("expected the secret to be hashed, got %q", token.Hash)
+	}
+	if other, _, _ := svc.Create(ctx, owner, "other", model.Scopes{model.TokenScopeRead}, time.Time{}); other == secret {
+		t.Error("expected every token to have its own secret")
+	}
+
+	authenticated, err := svc.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	} else if authenticated.ID != token.ID || authenticated.Owner != owner.User {
+		t.Errorf("expected token %d of %s, got %d of %s", token.ID, owner.User, authenticated.ID, authenticated.Owner)
+	} else if sub := authenticated.Subject(); !slices.Equal(sub.Groups, owner.Groups) {
+		t.Errorf("expected the token to act with groups %v, got %v", owner.Groups, sub.Groups)
+	} else if !authenticated.LastUsedAt.Valid || !authenticated.LastUsedAt.Time.Equal(now) {
+		t.Errorf("expected the token to be used at %s, got %+v", now, authenticated.LastUsedAt)
+	}
+
+	for _, secret := range []string{"", "not a token", Prefix, Prefix + "wrong", secret + "x"} {
+		if _, err := svc.Authenticate(ctx, secret); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: expected ErrInvalid, got %v", secret, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := svc.Authenticate(ctx, secret); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+	now = now.Add(-time.Minute)
+
+	// tokens are only listed and revoked by their owner.
+	if list, err := svc.List(ctx, "someone"); err != nil || len(list) != 0 {
+		t.Errorf("expected someone else to have no tokens, got %v, %v", list, err)
+	}
+	if list, err := svc.List(ctx, owner.User); err != nil || len(list) != 2 || list[1].ID != token.ID {
+		t.Errorf("expected 2 tokens, newest first, got %v, %v", list, err)
+	}
+	if err := svc.Revoke(ctx, "someone", token.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected someone else not to find the token, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, secret); err != nil {
+		t.Errorf("expected the token to still work, got %v", err)
+	}
+
+	if err := svc.Revoke(ctx, owner.User, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Revoke(ctx, owner.User, token.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a revoked token not to be found, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, secret); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a revoked token to be invalid, got %v", err)
+	}
+	if list, _ := svc.List(ctx, owner.User); len(list) != 1 {
+		t.Errorf("expected a revoked token not to be listed, got %v", list)
+	}
+}
@@ -3,6 +3,7 @@ package bimarshal
 import (
 	"encoding"
 	"encoding/json"
+	"fmt"
 
 	"github.com/tinylib/msgp/msgp"
 )
@@ -17,12 +18,27 @@ type (
 		encoding.BinaryUnmarshaler
 		encoding.BinaryMarshaler
 	}
+
+	codec interface {
+		codec() string
+		codecID() byte
+	}
 )
 
+// CodecName describes the encoding used by b, such as "json".
+func CodecName(b Bimarshal) string {
+	if c, ok := b.(codec); ok {
+		return c.codec()
+	}
+	return fmt.Sprintf("%T", b)
+}
+
 type msgpBimarshal[T msgpImpl] struct{ d T }
 
 func (a *msgpBimarshal[T]) MarshalBinary() ([]byte, error) { return a.d.MarshalMsg(nil) }
 func (a *msgpBimarshal[T]) UnmarshalBinary(b []byte) error { _, err := a.d.UnmarshalMsg(b); return err }
+func (a *msgpBimarshal[T]) codec() string                  { return "msgpack" }
+func (a *msgpBimarshal[T]) codecID() byte                  { return codecMessagePack }
 
 var _ Bimarshal = (*msgpBimarshal[msgpImpl])(nil)
 
@@ -32,6 +48,8 @@ type jsonBimarshal[T any] struct{ d T }
 
 func (a *jsonBimarshal[T]) MarshalBinary() ([]byte, error) { return json.Marshal(a.d) }
 func (a *jsonBimarshal[T]) UnmarshalBinary(b []byte) error { return json.Unmarshal(b, &a.d) }
+func (a *jsonBimarshal[T]) codec() string                  { return "json" }
+func (a *jsonBimarshal[T]) codecID() byte                  { return codecJSON }
 
 var _ Bimarshal = (*jsonBimarshal[any])(nil)
 
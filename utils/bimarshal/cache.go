@@ -2,59 +2,393 @@ package bimarshal
 
 import (
 	"context"
+	"crypto/cipher"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/Gardego5/garrettdavis.dev/utils/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Gardego5/garrettdavis.dev/utils/bimarshal")
+
+// ErrNotFound is returned by a Store or Cache when there is no entry for a key.
+var ErrNotFound = errors.New("bimarshal: key not found")
+
+const (
+	LookupHit Lookup = "hit"
+	// LookupStale is an entry that's served while it's refreshed.
+	LookupStale Lookup = "stale"
+	LookupMiss  Lookup = "miss"
+	LookupError Lookup = "error"
 )
 
 type (
+	// Registered is the part of a Cache that doesn't depend on its value type,
+	// so every registered cache can be inspected and managed the same way.
+	Registered interface {
+		Prefix() string
+		Codec() string
+		Type() reflect.Type
+
+		// Delete removes keys from the cache. Missing keys are ignored.
+		Delete(ctx context.Context, keys ...string) error
+		// TTL is how long key remains fresh, or 0 if it never expires.
+		TTL(ctx context.Context, key string) (time.Duration, error)
+		// Touch resets the expiry of key to ttl without changing its value.
+		Touch(ctx context.Context, key string, ttl time.Duration) error
+		// Keys lists every key in the cache that starts with prefix.
+		Keys(ctx context.Context, prefix string) ([]string, error)
+	}
+
 	Cache[T any] interface {
+		Registered
 		Set(ctx context.Context, key string, data T, ttl time.Duration) error
 		Get(ctx context.Context, key string) (*T, error)
-		GetOrSet(ctx context.Context, key string, f func() (*T, time.Duration, error)) (*T, error)
+		GetOrSet(ctx context.Context, key string, f func(ctx context.Context) (*T, time.Duration, error)) (*T, error)
+	}
+
+	// Store is the byte level storage a Cache is built on top of. Get must
+	// return ErrNotFound when the key is missing or expired.
+	Store interface {
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+		Delete(ctx context.Context, keys ...string) error
+		TTL(ctx context.Context, key string) (time.Duration, error)
+		Expire(ctx context.Context, key string, ttl time.Duration) error
+		Scan(ctx context.Context, prefix string) ([]string, error)
 	}
-	redisCache[T any] struct {
-		rdb *redis.Client
-		pre string
-		enc func(data *T) Bimarshal
+
+	Option  func(*options)
+	options struct {
+		stale       time.Duration
+		negativeTTL time.Duration
+		version     uint16
+		upgrades    map[uint16]func([]byte) ([]byte, error)
+		compress    bool
+		aead        cipher.AEAD
+		observe     func(prefix string, lookup Lookup)
+	}
+
+	// Lookup is how looking up a key went.
+	Lookup string
+
+	cache[T any] struct {
+		store  Store
+		pre    string
+		enc    func(data *T) Bimarshal
+		opts   options
+		now    func() time.Time
+		flight flight[T]
+
+		mu       sync.Mutex
+		negative map[string]negativeEntry
+		swept    time.Time
+	}
+	negativeEntry struct {
+		err     error
+		expires time.Time
 	}
 )
 
-func NewCache[T any](r *redis.Client, pre string, enc func(data *T) Bimarshal) Cache[T] {
-	return &redisCache[T]{r, pre, enc}
+// StaleWhileRevalidate keeps entries around for d after they expire. During
+// that window GetOrSet returns the old value immediately and refreshes it in
+// the background.
+func StaleWhileRevalidate(d time.Duration) Option { return func(o *options) { o.stale = d } }
+
+// NegativeTTL remembers loader errors in GetOrSet for d, so a failing
+// upstream isn't called again on every request.
+func NegativeTTL(d time.Duration) Option { return func(o *options) { o.negativeTTL = d } }
+
+// Version sets the current version of the cached type. Bump it whenever the
+// encoded form of the type changes, and register an Upgrade from the previous
+// version. Entries that can't be upgraded are treated as missing.
+func Version(v uint16) Option { return func(o *options) { o.version = v } }
+
+// Upgrade registers f to migrate an encoded value from version from to
+// from+1. f receives and returns the payload in the cache's codec.
+func Upgrade(from uint16, f func([]byte) ([]byte, error)) Option {
+	return func(o *options) {
+		if o.upgrades == nil {
+			o.upgrades = make(map[uint16]func([]byte) ([]byte, error))
+		}
+		o.upgrades[from] = f
+	}
+}
+
+// Compress compresses entries with zstd before they are stored.
+func Compress() Option { return func(o *options) { o.compress = true } }
+
+// Encrypt seals entries with aead before they are stored.
+func Encrypt(aead cipher.AEAD) Option { return func(o *options) { o.aead = aead } }
+
+// Observe calls f with how every Get and GetOrSet lookup went, along with the
+// prefix of the cache.
+func Observe(f func(prefix string, lookup Lookup)) Option {
+	return func(o *options) { o.observe = f }
 }
 
-func (r *redisCache[T]) key(key string) string { return fmt.Sprintf("%s:%s", r.pre, key) }
+func NewCache[T any](store Store, pre string, enc func(data *T) Bimarshal, opts ...Option) Cache[T] {
+	c := &cache[T]{store: store, pre: pre, enc: enc, now: time.Now}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	return c
+}
+
+func (c *cache[T]) key(key string) string { return fmt.Sprintf("%s:%s", c.pre, key) }
+
+func (c *cache[T]) Prefix() string     { return c.pre }
+func (c *cache[T]) Codec() string      { return CodecName(c.enc(new(T))) }
+func (c *cache[T]) Type() reflect.Type { return reflect.TypeFor[T]() }
+
+// encode marshals data into an envelope, recording when the entry stops being
+// fresh if stale-while-revalidate is enabled.
+func (c *cache[T]) encode(key string, data *T, ttl time.Duration) ([]byte, time.Duration, error) {
+	b := c.enc(data)
+	payload, err := b.MarshalBinary()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	e := &envelope{codec: codecID(b), version: c.opts.version, payload: payload}
+	if c.opts.stale > 0 {
+		e.flags |= flagFresh
+		if ttl > 0 {
+			e.fresh = c.now().Add(ttl).UnixNano()
+			ttl += c.opts.stale
+		}
+	}
 
-func (r *redisCache[T]) Set(ctx context.Context, key string, data T, ttl time.Duration) error {
-	return r.rdb.Set(ctx, r.key(key), r.enc(&data), ttl).Err()
+	encoded, err := e.seal(key, &c.opts)
+	return encoded, ttl, err
 }
 
-func (r *redisCache[T]) Get(ctx context.Context, key string) (*T, error) {
-	encoded, err := r.rdb.Get(ctx, r.key(key)).Result()
+func (c *cache[T]) decode(key string, encoded []byte) (data *T, fresh bool, err error) {
+	e, err := openEnvelope(encoded, key, &c.opts)
 	if err != nil {
+		return nil, false, err
+	}
+
+	data = new(T)
+	b := c.enc(data)
+	if id := codecID(b); e.codec != id {
+		return nil, false, errIncompatible
+	}
+	if err = e.upgrade(c.opts.version, c.opts.upgrades); err != nil {
+		return nil, false, err
+	}
+
+	fresh = e.fresh == 0 || c.now().UnixNano() < e.fresh
+	return data, fresh, b.UnmarshalBinary(e.payload)
+}
+
+func (c *cache[T]) set(ctx context.Context, key string, data *T, ttl time.Duration) error {
+	encoded, ttl, err := c.encode(c.key(key), data, ttl)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, c.key(key), encoded, ttl)
+}
+
+func (c *cache[T]) Set(ctx context.Context, key string, data T, ttl time.Duration) (err error) {
+	ctx, span := c.start(ctx, "Set")
+	defer func() { c.end(span, err) }()
+
+	return c.set(ctx, key, &data, ttl)
+}
+
+// start starts a span for op. keys aren't recorded, since some are
+// credentials.
+func (c *cache[T]) start(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, op+" "+c.pre, trace.WithAttributes(attribute.String("cache.prefix", c.pre)))
+}
+
+// end ends span. A missing key isn't an error.
+func (c *cache[T]) end(span trace.Span, err error) {
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+// observe records how a lookup went, by its error if lookup is empty.
+func (c *cache[T]) observe(ctx context.Context, lookup Lookup, err error) {
+	if lookup == "" {
+		switch {
+		case err == nil:
+			lookup = LookupHit
+		case errors.Is(err, ErrNotFound):
+			lookup = LookupMiss
+		default:
+			lookup = LookupError
+		}
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.lookup", string(lookup)))
+	if c.opts.observe != nil {
+		c.opts.observe(c.pre, lookup)
+	}
+}
+
+func (c *cache[T]) Get(ctx context.Context, key string) (_ *T, err error) {
+	ctx, span := c.start(ctx, "Get")
+	defer func() { c.end(span, err) }()
+
+	encoded, err := c.store.Get(ctx, c.key(key))
+	if err != nil {
+		c.observe(ctx, "", err)
 		return nil, err
 	}
 
-	data := new(T)
-	err = r.enc(data).UnmarshalBinary([]byte(encoded))
+	data, fresh, err := c.decode(c.key(key), encoded)
+	if errors.Is(err, errIncompatible) || (err == nil && !fresh) {
+		err = ErrNotFound
+		data = nil
+	}
+	c.observe(ctx, "", err)
 	return data, err
 }
 
-func (r *redisCache[T]) GetOrSet(ctx context.Context, key string, f func() (*T, time.Duration, error)) (*T, error) {
-	encoded, err := r.rdb.Get(ctx, r.key(key)).Result()
+func (c *cache[T]) GetOrSet(ctx context.Context, key string, f func(ctx context.Context) (*T, time.Duration, error)) (_ *T, err error) {
+	ctx, span := c.start(ctx, "GetOrSet")
+	defer func() { c.end(span, err) }()
+
+	encoded, err := c.store.Get(ctx, c.key(key))
 	if err == nil {
-		data := new(T)
-		err = r.enc(data).UnmarshalBinary([]byte(encoded))
-		return data, err
+		var data *T
+		var fresh bool
+		data, fresh, err = c.decode(c.key(key), encoded)
+		if err == nil && !fresh {
+			c.observe(ctx, LookupStale, nil)
+			go c.load(context.WithoutCancel(ctx), key, f)
+			return data, nil
+		}
+		if !errors.Is(err, errIncompatible) {
+			c.observe(ctx, "", err)
+			return data, err
+		}
+		err = ErrNotFound
+	}
+	c.observe(ctx, "", err)
+	return c.load(ctx, key, f)
+}
+
+// load calls f at most once at a time per key, and stores the result. Every
+// caller waiting on the key shares the result, so f isn't cancelled along with
+// the caller that happened to start it.
+func (c *cache[T]) load(ctx context.Context, key string, f func(ctx context.Context) (*T, time.Duration, error)) (_ *T, err error) {
+	ctx, span := c.start(ctx, "load")
+	defer func() { c.end(span, err) }()
+
+	return c.flight.do(key, func() (*T, error) {
+		if err := c.remembered(key); err != nil {
+			return nil, err
+		}
+
+		ctx := context.WithoutCancel(ctx)
+		data, ttl, err := f(ctx)
+		if err != nil {
+			// running out of time says nothing about the upstream.
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				c.remember(key, err)
+			}
+			return nil, err
+		}
+		if err = c.set(ctx, key, data, ttl); err != nil {
+			return nil, err
+		}
+		return data, nil
+	})
+}
+
+func (c *cache[T]) remember(key string, err error) {
+	if c.opts.negativeTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.negative == nil {
+		c.negative = make(map[string]negativeEntry)
 	}
-	data, ttl, err := f()
+
+	// keys that are never looked up again would otherwise be kept forever.
+	now := c.now()
+	if now.Sub(c.swept) >= c.opts.negativeTTL {
+		for k, entry := range c.negative {
+			if !now.Before(entry.expires) {
+				delete(c.negative, k)
+			}
+		}
+		c.swept = now
+	}
+	c.negative[key] = negativeEntry{err, now.Add(c.opts.negativeTTL)}
+}
+
+func (c *cache[T]) remembered(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.negative[key]
+	if !ok {
+		return nil
+	} else if !c.now().Before(entry.expires) {
+		delete(c.negative, key)
+		return nil
+	}
+	return entry.err
+}
+
+func (c *cache[T]) Delete(ctx context.Context, keys ...string) (err error) {
+	ctx, span := c.start(ctx, "Delete")
+	defer func() { c.end(span, err) }()
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return c.store.Delete(ctx, prefixed...)
+}
+
+func (c *cache[T]) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.store.TTL(ctx, c.key(key))
+	if err != nil || ttl == 0 || c.opts.stale <= 0 {
+		return ttl, err
+	}
+	// the stored entry outlives its freshness by the stale window
+	return max(ttl-c.opts.stale, 0), nil
+}
+
+func (c *cache[T]) Touch(ctx context.Context, key string, ttl time.Duration) error {
+	if c.opts.stale <= 0 {
+		return c.store.Expire(ctx, c.key(key), ttl)
+	}
+
+	// freshness is recorded in the entry itself, so it has to be rewritten.
+	encoded, err := c.store.Get(ctx, c.key(key))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if err = r.rdb.Set(ctx, r.key(key), r.enc(data), ttl).Err(); err != nil {
-		return nil, err
+	data, _, err := c.decode(c.key(key), encoded)
+	if errors.Is(err, errIncompatible) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return c.set(ctx, key, data, ttl)
+}
+
+func (c *cache[T]) Keys(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := c.store.Scan(ctx, c.key(prefix))
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, c.pre+":")
 	}
-	return data, nil
+	return keys, err
 }
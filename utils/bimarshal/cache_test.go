@@ -0,0 +1,222 @@
+package bimarshal_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+)
+
+func TestGetOrSetDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(NewMemoryStore(10))
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (*testValue, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return &testValue{"loaded", 1}, time.Minute, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetOrSet(ctx, "key", load); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected loader to be called once, called %d times", n)
+	}
+}
+
+func TestGetOrSetStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	store := NewMemoryStoreWithClock(10, clk.now)
+	c := NewCacheWithClock[testValue](store, "test", JSON, clk.now, StaleWhileRevalidate(time.Minute))
+
+	refreshed := make(chan struct{})
+	c.Set(ctx, "key", testValue{"old", 1}, time.Second)
+	clk.advance(2 * time.Second)
+
+	if _, err := c.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected Get to ignore stale entry, got %v", err)
+	}
+
+	got, err := c.GetOrSet(ctx, "key", func(context.Context) (*testValue, time.Duration, error) {
+		defer close(refreshed)
+		return &testValue{"new", 2}, time.Second, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "old" {
+		t.Errorf("expected stale value to be served, got %v", *got)
+	}
+
+	<-refreshed
+	time.Sleep(10 * time.Millisecond)
+	if got, err := c.Get(ctx, "key"); err != nil || got.Name != "new" {
+		t.Errorf("expected refreshed value, got %v, %v", got, err)
+	}
+}
+
+func TestGetOrSetNegativeTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	c := NewCacheWithClock[testValue](NewMemoryStore(10), "test", JSON, clk.now, NegativeTTL(time.Second))
+
+	calls := 0
+	loadErr := errors.New("upstream down")
+	load := func(context.Context) (*testValue, time.Duration, error) {
+		calls++
+		if calls == 1 {
+			return nil, 0, loadErr
+		}
+		return &testValue{"loaded", calls}, time.Minute, nil
+	}
+
+	for range 2 {
+		if _, err := c.GetOrSet(ctx, "key", load); !errors.Is(err, loadErr) {
+			t.Errorf("expected loader error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected error to be remembered, loader called %d times", calls)
+	}
+
+	clk.advance(time.Second)
+	if _, err := c.GetOrSet(ctx, "key", load); err != nil {
+		t.Errorf("expected loader to be retried after negative ttl, got %v", err)
+	}
+
+	// errors for keys that aren't looked up again are swept away.
+	fail := func(context.Context) (*testValue, time.Duration, error) { return nil, 0, loadErr }
+	c.GetOrSet(ctx, "once", fail)
+	clk.advance(time.Second)
+	c.GetOrSet(ctx, "twice", fail)
+	if n := Remembered(c); n != 1 {
+		t.Errorf("expected expired errors to be forgotten, remembering %d", n)
+	}
+}
+
+func TestGetOrSetCancelled(t *testing.T) {
+	c := NewCacheWithClock[testValue](NewMemoryStore(10), "test", JSON, newClock().now, NegativeTTL(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the loader is shared by every caller, so it outlives the one that
+	// started it.
+	_, err := c.GetOrSet(ctx, "key", func(ctx context.Context) (*testValue, time.Duration, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, context.DeadlineExceeded
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the loader not to be cancelled, got %v", err)
+	}
+
+	calls := 0
+	if _, err = c.GetOrSet(context.Background(), "key", func(context.Context) (*testValue, time.Duration, error) {
+		calls++
+		return &testValue{"loaded", 1}, time.Minute, nil
+	}); err != nil || calls != 1 {
+		t.Errorf("expected a timed out load not to be remembered, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCacheManagement(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	store := NewMemoryStoreWithClock(10, clk.now)
+	c := NewCacheWithClock[testValue](store, "test", JSON, clk.now)
+	other := NewCacheWithClock[testValue](store, "other", JSON, clk.now)
+
+	c.Set(ctx, "session:a", testValue{"a", 1}, time.Minute)
+	c.Set(ctx, "session:b", testValue{"b", 2}, 0)
+	c.Set(ctx, "user:c", testValue{"c", 3}, time.Minute)
+	other.Set(ctx, "session:d", testValue{"d", 4}, time.Minute)
+
+	keys, err := c.Keys(ctx, "session:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(keys, []string{"session:a", "session:b"}) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if ttl, err := c.TTL(ctx, "session:b"); err != nil || ttl != 0 {
+		t.Errorf("expected no expiry, got %v, %v", ttl, err)
+	}
+
+	clk.advance(30 * time.Second)
+	if ttl, err := c.TTL(ctx, "session:a"); err != nil || ttl != 30*time.Second {
+		t.Errorf("expected 30s remaining, got %v, %v", ttl, err)
+	}
+	if err := c.Touch(ctx, "session:a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := c.TTL(ctx, "session:a"); ttl != time.Minute {
+		t.Errorf("expected touch to reset ttl, got %v", ttl)
+	}
+	if err := c.Touch(ctx, "missing", time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound touching missing key, got %v", err)
+	}
+
+	if err := c.Delete(ctx, keys...); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := c.Keys(ctx, ""); !slices.Equal(keys, []string{"user:c"}) {
+		t.Errorf("unexpected keys after delete %v", keys)
+	}
+	if keys, _ := other.Keys(ctx, ""); !slices.Equal(keys, []string{"session:d"}) {
+		t.Errorf("expected other cache to be untouched, got %v", keys)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	store := NewMemoryStoreWithClock(10, clk.now)
+
+	var lookups []Lookup
+	c := NewCacheWithClock[testValue](store, "test", JSON, clk.now,
+		StaleWhileRevalidate(time.Minute),
+		Observe(func(prefix string, lookup Lookup) {
+			if prefix != "test" {
+				t.Errorf("expected the prefix of the cache, got %q", prefix)
+			}
+			lookups = append(lookups, lookup)
+		}))
+	load := func(context.Context) (*testValue, time.Duration, error) {
+		return &testValue{"loaded", 1}, time.Second, nil
+	}
+
+	c.Get(ctx, "key")
+	c.GetOrSet(ctx, "key", load)
+	c.Get(ctx, "key")
+	c.GetOrSet(ctx, "key", load)
+	clk.advance(2 * time.Second)
+	c.Get(ctx, "key")
+	c.GetOrSet(ctx, "key", load)
+
+	expected := []Lookup{LookupMiss, LookupMiss, LookupHit, LookupHit, LookupMiss, LookupStale}
+	if !slices.Equal(lookups, expected) {
+		t.Errorf("expected lookups %v, got %v", expected, lookups)
+	}
+}
@@ -2,31 +2,58 @@ package bimarshal
 
 import (
 	"reflect"
-
-	"github.com/redis/go-redis/v9"
+	"slices"
+	"strings"
 )
 
 type (
-	registration[T any] struct{ enc func(data *T) Bimarshal }
-	register            interface {
-		register(*redis.Client, map[reflect.Type]any, string)
+	registration[T any] struct {
+		enc  func(data *T) Bimarshal
+		opts []Option
+	}
+	register interface {
+		register(Store, map[reflect.Type]any, string, []Option)
 	}
 	Caches           map[string]register
 	RegisteredCaches map[reflect.Type]any
 )
 
-func (c Caches) Build(rdb *redis.Client) RegisteredCaches {
+// Build creates every cache in store. opts apply to all of them, after the
+// options they were registered with.
+func (c Caches) Build(store Store, opts ...Option) RegisteredCaches {
 	m := make(RegisteredCaches)
 	for k, v := range c {
-		v.register(rdb, m, k)
+		v.register(store, m, k, opts)
 	}
 	return m
 }
 
-func (r registration[T]) register(rdb *redis.Client, c map[reflect.Type]any, prefix string) {
-	c[reflect.TypeFor[T]()] = NewCache[T](rdb, prefix, r.enc)
+func (r registration[T]) register(store Store, c map[reflect.Type]any, prefix string, opts []Option) {
+	c[reflect.TypeFor[T]()] = NewCache[T](store, prefix, r.enc, slices.Concat(r.opts, opts)...)
 }
 
-func Register[T any](enc func(data *T) Bimarshal) registration[T] { return registration[T]{enc} }
+func Register[T any](enc func(data *T) Bimarshal, opts ...Option) registration[T] {
+	return registration[T]{enc, opts}
+}
 
 func Get[T any](caches RegisteredCaches) Cache[T] { return caches[reflect.TypeFor[T]()].(Cache[T]) }
+
+// List returns every registered cache, ordered by prefix.
+func (c RegisteredCaches) List() []Registered {
+	list := make([]Registered, 0, len(c))
+	for _, v := range c {
+		list = append(list, v.(Registered))
+	}
+	slices.SortFunc(list, func(a, b Registered) int { return strings.Compare(a.Prefix(), b.Prefix()) })
+	return list
+}
+
+// Lookup finds the registered cache using prefix.
+func (c RegisteredCaches) Lookup(prefix string) (Registered, bool) {
+	for _, v := range c {
+		if r := v.(Registered); r.Prefix() == prefix {
+			return r, true
+		}
+	}
+	return nil, false
+}
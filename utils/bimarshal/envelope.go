@@ -0,0 +1,165 @@
+package bimarshal
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Every value written by a Cache is wrapped in a small envelope so that
+// entries written with a different codec or an older version of the type can
+// be recognized, instead of being decoded into garbage.
+//
+//	magic   1 byte  (0xc1, never used by msgpack & invalid json)
+//	codec   1 byte  (see codecID)
+//	flags   1 byte  (see flag*)
+//	version 2 bytes (big endian, see Version)
+//	fresh   8 bytes (only with flagFresh, unix nanoseconds)
+//	payload
+const envelopeMagic byte = 0xc1
+
+const (
+	flagCompressed byte = 1 << iota
+	flagEncrypted
+	flagFresh
+)
+
+const (
+	codecUnknown byte = iota
+	codecJSON
+	codecMessagePack
+)
+
+// errIncompatible is returned when an entry exists, but can't be read by this
+// cache. Callers treat it the same as a missing entry.
+var errIncompatible = errors.New("bimarshal: incompatible entry")
+
+type envelope struct {
+	codec   byte
+	flags   byte
+	version uint16
+	fresh   int64
+	payload []byte
+}
+
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, _ := zstd.NewWriter(nil)
+		return enc
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, _ := zstd.NewReader(nil)
+		return dec
+	})
+)
+
+func codecID(b Bimarshal) byte {
+	if c, ok := b.(codec); ok {
+		return c.codecID()
+	}
+	return codecUnknown
+}
+
+func (e *envelope) header() []byte {
+	h := []byte{envelopeMagic, e.codec, e.flags, 0, 0}
+	binary.BigEndian.PutUint16(h[3:], e.version)
+	if e.flags&flagFresh != 0 {
+		h = binary.BigEndian.AppendUint64(h, uint64(e.fresh))
+	}
+	return h
+}
+
+// seal compresses and encrypts the payload according to opts, and returns the
+// complete encoded envelope. key is bound to the ciphertext so an encrypted
+// value can't be moved to another key.
+func (e *envelope) seal(key string, opts *options) ([]byte, error) {
+	if opts.compress {
+		e.flags |= flagCompressed
+		e.payload = zstdEncoder().EncodeAll(e.payload, nil)
+	}
+	if opts.aead != nil {
+		e.flags |= flagEncrypted
+	}
+
+	header := e.header()
+	if opts.aead == nil {
+		return append(header, e.payload...), nil
+	}
+
+	nonce := make([]byte, opts.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(header, nonce...)
+	return opts.aead.Seal(sealed, nonce, e.payload, additionalData(header, key)), nil
+}
+
+// openEnvelope parses an encoded envelope, decrypting and decompressing the
+// payload.
+func openEnvelope(b []byte, key string, opts *options) (*envelope, error) {
+	if len(b) < 5 || b[0] != envelopeMagic {
+		return nil, errIncompatible
+	}
+
+	e := &envelope{codec: b[1], flags: b[2], version: binary.BigEndian.Uint16(b[3:5])}
+	n := 5
+	if e.flags&flagFresh != 0 {
+		if len(b) < n+8 {
+			return nil, errIncompatible
+		}
+		e.fresh = int64(binary.BigEndian.Uint64(b[n:]))
+		n += 8
+	}
+	header := b[:n]
+	e.payload = b[n:]
+
+	if e.flags&flagEncrypted != 0 {
+		if opts.aead == nil || len(e.payload) < opts.aead.NonceSize() {
+			return nil, errIncompatible
+		}
+		nonce, sealed := e.payload[:opts.aead.NonceSize()], e.payload[opts.aead.NonceSize():]
+		payload, err := opts.aead.Open(nil, nonce, sealed, additionalData(header, key))
+		if err != nil {
+			// most likely the secret has changed since it was written.
+			return nil, errIncompatible
+		}
+		e.payload = payload
+	}
+
+	if e.flags&flagCompressed != 0 {
+		payload, err := zstdDecoder().DecodeAll(e.payload, nil)
+		if err != nil {
+			return nil, err
+		}
+		e.payload = payload
+	}
+
+	return e, nil
+}
+
+func additionalData(header []byte, key string) []byte {
+	return append(append([]byte(nil), header...), key...)
+}
+
+// upgrade migrates the payload to version using the registered upgrades.
+func (e *envelope) upgrade(version uint16, upgrades map[uint16]func([]byte) ([]byte, error)) error {
+	if e.version > version {
+		// written by a newer version of the type, probably mid deploy.
+		return errIncompatible
+	}
+	for ; e.version < version; e.version++ {
+		upgrade, ok := upgrades[e.version]
+		if !ok {
+			return errIncompatible
+		}
+		payload, err := upgrade(e.payload)
+		if err != nil {
+			return err
+		}
+		e.payload = payload
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package bimarshal_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/model"
+	. "github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+)
+
+func testAEAD(t *testing.T, secret string) cipher.AEAD {
+	block, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestEnvelopeCompressedEncrypted(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	aead := testAEAD(t, "0123456789abcdef")
+	c := NewCache[testValue](store, "test", JSON, Compress(), Encrypt(aead))
+
+	value := testValue{"a secret that should not be stored in plain text", 1}
+	if err := c.Set(ctx, "key", value, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := store.Get(ctx, "test:key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Errorf("expected stored entry to be encrypted, got %q", raw)
+	}
+
+	if got, err := c.Get(ctx, "key"); err != nil || *got != value {
+		t.Errorf("expected %v, got %v, %v", value, got, err)
+	}
+
+	// an entry moved to another key must not decrypt
+	store.Set(ctx, "test:other", raw, time.Minute)
+	if _, err := c.Get(ctx, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected moved entry to be ErrNotFound, got %v", err)
+	}
+
+	// nor with a different secret
+	rotated := NewCache[testValue](store, "test", JSON, Compress(), Encrypt(testAEAD(t, "fedcba9876543210")))
+	if _, err := rotated.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected entry from another secret to be ErrNotFound, got %v", err)
+	}
+}
+
+func TestEnvelopeIncompatibleEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	c := NewCache[testValue](store, "test", JSON)
+
+	// written before entries had an envelope
+	store.Set(ctx, "test:legacy", []byte(`{"name":"legacy","count":1}`), time.Minute)
+	if _, err := c.Get(ctx, "legacy"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected legacy entry to be ErrNotFound, got %v", err)
+	}
+
+	// written with a different codec
+	NewCache[model.Subject](store, "test", MessagePack).Set(ctx, "msgp", model.Subject{User: "someone"}, time.Minute)
+	got, err := c.GetOrSet(ctx, "msgp", func(context.Context) (*testValue, time.Duration, error) {
+		return &testValue{"reloaded", 2}, time.Minute, nil
+	})
+	if err != nil || got.Name != "reloaded" {
+		t.Errorf("expected entry with another codec to be reloaded, got %v, %v", got, err)
+	}
+}
+
+func TestEnvelopeUpgrade(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+
+	type v0 struct {
+		Title string `json:"title"`
+	}
+	NewCache[v0](store, "test", JSON).Set(ctx, "key", v0{"renamed"}, time.Minute)
+
+	c := NewCache[testValue](store, "test", JSON, Version(1),
+		Upgrade(0, func(b []byte) ([]byte, error) {
+			var old v0
+			if err := json.Unmarshal(b, &old); err != nil {
+				return nil, err
+			}
+			return json.Marshal(testValue{Name: old.Title})
+		}))
+
+	if got, err := c.Get(ctx, "key"); err != nil || got.Name != "renamed" {
+		t.Errorf("expected upgraded value, got %v, %v", got, err)
+	}
+
+	// there's no upgrade from version 1, so version 2 can't read it
+	c.Set(ctx, "key", testValue{"current", 1}, time.Minute)
+	if _, err := NewCache[testValue](store, "test", JSON, Version(2)).Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected entry without upgrade path to be ErrNotFound, got %v", err)
+	}
+	// and version 0 can't read newer entries
+	if _, err := NewCache[testValue](store, "test", JSON).Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected entry from newer version to be ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package bimarshal
+
+import (
+	"context"
+	"time"
+)
+
+func NewMemoryStoreWithClock(size int, now func() time.Time) Store {
+	return newMemoryStore(size, now)
+}
+
+func NewCacheWithClock[T any](store Store, pre string, enc func(data *T) Bimarshal, now func() time.Time, opts ...Option) Cache[T] {
+	c := NewCache(store, pre, enc, opts...).(*cache[T])
+	c.now = now
+	return c
+}
+
+// Remembered is how many loader errors c is remembering, expired or not.
+func Remembered[T any](c Cache[T]) int {
+	cc := c.(*cache[T])
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.negative)
+}
+
+// LocalTTL is how long t keeps its local copy of key for.
+func LocalTTL(t *TieredStore, key string) time.Duration {
+	ttl, _ := t.local.TTL(context.Background(), key)
+	return ttl
+}
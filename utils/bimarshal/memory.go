@@ -0,0 +1,156 @@
+package bimarshal
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// memoryStore is an in process Store bounded to a maximum number of
+	// entries. When full, the least recently used entry is evicted.
+	memoryStore struct {
+		mu      sync.Mutex
+		size    int
+		entries map[string]*list.Element
+		lru     *list.List
+		now     func() time.Time
+	}
+	memoryEntry struct {
+		key     string
+		value   []byte
+		expires time.Time
+	}
+)
+
+var _ Store = (*memoryStore)(nil)
+
+// NewMemoryStore creates an in process Store holding at most size entries. A
+// size less than one means the store is unbounded.
+func NewMemoryStore(size int) Store { return newMemoryStore(size, time.Now) }
+
+func newMemoryStore(size int, now func() time.Time) *memoryStore {
+	return &memoryStore{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     now,
+	}
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.entry(key)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lru.MoveToFront(m.entries[key])
+	return append([]byte(nil), entry.value...), nil
+}
+
+func (m *memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = m.now().Add(ttl)
+	}
+
+	if el, ok := m.entries[key]; ok {
+		el.Value = entry
+		m.lru.MoveToFront(el)
+		return nil
+	}
+
+	m.entries[key] = m.lru.PushFront(entry)
+	for m.size > 0 && m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := m.entries[key]; ok {
+			m.remove(el)
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.entry(key)
+	if err != nil {
+		return 0, err
+	} else if entry.expires.IsZero() {
+		return 0, nil
+	}
+	return entry.expires.Sub(m.now()), nil
+}
+
+func (m *memoryStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.entry(key)
+	if err != nil {
+		return err
+	}
+	entry.expires = time.Time{}
+	if ttl > 0 {
+		entry.expires = m.now().Add(ttl)
+	}
+	return nil
+}
+
+func (m *memoryStore) Scan(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	keys := []string{}
+	for key, el := range m.entries {
+		if strings.HasPrefix(key, prefix) && !el.Value.(*memoryEntry).expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// entry returns the live entry for key. m.mu must be held.
+func (m *memoryStore) entry(key string) (*memoryEntry, error) {
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if entry.expired(m.now()) {
+		m.remove(el)
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
+
+func (m *memoryStore) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
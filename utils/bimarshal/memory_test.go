@@ -0,0 +1,116 @@
+package bimarshal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+)
+
+type testValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClock() *clock                   { return &clock{time.Unix(0, 0)} }
+func newTestCache(store Store) Cache[testValue] {
+	return NewCache[testValue](store, "test", JSON)
+}
+
+func TestMemoryGetSet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(NewMemoryStore(10))
+
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := c.Set(ctx, "a", testValue{"a", 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != (testValue{"a", 1}) {
+		t.Errorf("expected %v, got %v", testValue{"a", 1}, *got)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	clk := newClock()
+	c := newTestCache(NewMemoryStoreWithClock(10, clk.now))
+
+	c.Set(ctx, "short", testValue{"short", 1}, time.Second)
+	c.Set(ctx, "forever", testValue{"forever", 2}, 0)
+
+	clk.advance(time.Second)
+
+	if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected expired entry to be ErrNotFound, got %v", err)
+	}
+	if _, err := c.Get(ctx, "forever"); err != nil {
+		t.Errorf("expected entry without ttl to remain, got %v", err)
+	}
+}
+
+func TestMemoryEviction(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(NewMemoryStore(2))
+
+	c.Set(ctx, "a", testValue{"a", 1}, 0)
+	c.Set(ctx, "b", testValue{"b", 2}, 0)
+	c.Get(ctx, "a") // a is now the most recently used
+	c.Set(ctx, "c", testValue{"c", 3}, 0)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected least recently used entry to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("expected %s to remain, got %v", key, err)
+		}
+	}
+}
+
+func TestMemoryGetOrSet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(NewMemoryStore(10))
+
+	calls := 0
+	load := func(context.Context) (*testValue, time.Duration, error) {
+		calls++
+		return &testValue{"loaded", calls}, time.Minute, nil
+	}
+
+	for range 3 {
+		got, err := c.GetOrSet(ctx, "key", load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != (testValue{"loaded", 1}) {
+			t.Errorf("expected %v, got %v", testValue{"loaded", 1}, *got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected loader to be called once, called %d times", calls)
+	}
+
+	loadErr := errors.New("load failed")
+	if _, err := c.GetOrSet(ctx, "other", func(context.Context) (*testValue, time.Duration, error) {
+		return nil, 0, loadErr
+	}); !errors.Is(err, loadErr) {
+		t.Errorf("expected loader error, got %v", err)
+	}
+	if _, err := c.Get(ctx, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected failed load not to be cached, got %v", err)
+	}
+}
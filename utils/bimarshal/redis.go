@@ -0,0 +1,106 @@
+package bimarshal
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisStore struct{ rdb *redis.Client }
+
+var _ Store = (*redisStore)(nil)
+
+func NewRedisStore(rdb *redis.Client) Store { return &redisStore{rdb} }
+
+func (r *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	encoded, err := r.rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return encoded, err
+}
+
+func (r *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+func (r *redisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return remaining(r.rdb.PTTL(ctx, key).Result())
+}
+
+// getWithTTL gets the value of key, along with how long it has left, or 0 if
+// it never expires.
+func (r *redisStore) getWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	pipe := r.rdb.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err == redis.Nil {
+		return nil, 0, ErrNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	ttl, err := remaining(pttl.Result())
+	if err != nil {
+		return nil, 0, err
+	}
+	return []byte(get.Val()), ttl, nil
+}
+
+// remaining interprets the result of PTTL.
+func remaining(ttl time.Duration, err error) (time.Duration, error) {
+	switch {
+	case err != nil:
+		return 0, err
+	case ttl == -2: // the key doesn't exist
+		return 0, ErrNotFound
+	case ttl == -1: // the key has no expiry
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (r *redisStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	var ok bool
+	var err error
+	if ttl > 0 {
+		ok, err = r.rdb.PExpire(ctx, key, ttl).Result()
+	} else {
+		ok, err = r.rdb.Persist(ctx, key).Result()
+		if err == nil && !ok {
+			// persist is also false for keys that already have no expiry
+			ok, err = r.exists(ctx, key)
+		}
+	}
+	if err == nil && !ok {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *redisStore) exists(ctx context.Context, key string) (bool, error) {
+	n, err := r.rdb.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
+func (r *redisStore) Scan(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	iter := r.rdb.Scan(ctx, 0, globEscaper.Replace(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	slices.Sort(keys)
+	return keys, iter.Err()
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
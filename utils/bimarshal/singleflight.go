@@ -0,0 +1,43 @@
+package bimarshal
+
+import "sync"
+
+type (
+	// flight de-duplicates concurrent calls for the same key, so that only the
+	// first caller does the work and everyone else waits for its result.
+	flight[T any] struct {
+		mu    sync.Mutex
+		calls map[string]*flightCall[T]
+	}
+	flightCall[T any] struct {
+		wg  sync.WaitGroup
+		val *T
+		err error
+	}
+)
+
+func (f *flight[T]) do(key string, fn func() (*T, error)) (*T, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall[T])
+	}
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(flightCall[T])
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
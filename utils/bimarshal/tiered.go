@@ -0,0 +1,134 @@
+package bimarshal
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// TieredStore keeps a small local copy of redis entries in process. Writes
+// are published over redis pub/sub so that other instances drop their local
+// copies instead of serving an old value.
+type TieredStore struct {
+	local   *memoryStore
+	remote  *redisStore
+	rdb     *redis.Client
+	channel string
+	id      string
+	ttl     time.Duration
+	sub     *redis.PubSub
+	done    chan struct{}
+}
+
+var _ Store = (*TieredStore)(nil)
+
+const invalidationChannel = "bimarshal:invalidate"
+
+// NewTieredStore creates a Store that reads through a local cache of up to
+// size entries before going to redis. Local entries live for at most ttl. It
+// listens for invalidations from other instances until it's closed.
+func NewTieredStore(rdb *redis.Client, size int, ttl time.Duration) *TieredStore {
+	t := &TieredStore{
+		local:   newMemoryStore(size, time.Now),
+		remote:  &redisStore{rdb},
+		rdb:     rdb,
+		channel: invalidationChannel,
+		id:      uuid.NewString(),
+		ttl:     ttl,
+		done:    make(chan struct{}),
+	}
+	t.sub = rdb.Subscribe(context.Background(), t.channel)
+	go t.subscribe(t.sub.Channel())
+	return t
+}
+
+// Close stops listening for invalidations. The store can still be used, but
+// local copies may then be out of date for up to ttl.
+func (t *TieredStore) Close() error {
+	err := t.sub.Close()
+	<-t.done
+	return err
+}
+
+func (t *TieredStore) localTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > t.ttl {
+		return t.ttl
+	}
+	return ttl
+}
+
+func (t *TieredStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, err := t.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	// local copies never outlive the entry in redis.
+	value, ttl, err := t.remote.getWithTTL(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	t.local.Set(ctx, key, value, t.localTTL(ttl))
+	return value, nil
+}
+
+func (t *TieredStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	t.local.Set(ctx, key, value, t.localTTL(ttl))
+	return t.invalidate(ctx, key)
+}
+
+func (t *TieredStore) Delete(ctx context.Context, keys ...string) error {
+	if err := t.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	t.local.Delete(ctx, keys...)
+	return t.invalidate(ctx, keys...)
+}
+
+func (t *TieredStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.remote.TTL(ctx, key)
+}
+
+func (t *TieredStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := t.remote.Expire(ctx, key, ttl); err != nil {
+		return err
+	}
+	t.local.Delete(ctx, key)
+	return t.invalidate(ctx, key)
+}
+
+func (t *TieredStore) Scan(ctx context.Context, prefix string) ([]string, error) {
+	return t.remote.Scan(ctx, prefix)
+}
+
+// invalidate tells every other instance to drop its local copy of keys.
+func (t *TieredStore) invalidate(ctx context.Context, keys ...string) error {
+	pipe := t.rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Publish(ctx, t.channel, t.id+" "+key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (t *TieredStore) subscribe(messages <-chan *redis.Message) {
+	defer close(t.done)
+	logger := slog.Default().With("scope", "bimarshal.TieredStore")
+
+	for msg := range messages {
+		id, key, ok := strings.Cut(msg.Payload, " ")
+		if !ok {
+			logger.Warn("malformed invalidation message", "payload", msg.Payload)
+			continue
+		} else if id == t.id {
+			continue
+		}
+		t.local.Delete(context.Background(), key)
+	}
+}
@@ -0,0 +1,256 @@
+package bimarshal_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTieredStore(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t)
+	a := NewTieredStore(server.client(t), 10, time.Minute)
+	b := NewTieredStore(server.client(t), 10, time.Minute)
+	server.waitForSubscribers(t, 2)
+
+	a.Set(ctx, "key", []byte("old"), 0)
+	if value, err := b.Get(ctx, "key"); err != nil || string(value) != "old" {
+		t.Fatalf("expected to read through to redis, got %q, %v", value, err)
+	}
+
+	// b keeps its own copy, until a tells it that it's changed.
+	server.client(t).Set(ctx, "key", "unannounced", 0)
+	if value, _ := b.Get(ctx, "key"); string(value) != "old" {
+		t.Errorf("expected the local copy to be used, got %q", value)
+	}
+	a.Set(ctx, "key", []byte("new"), 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		value, err := b.Get(ctx, "key")
+		if err == nil && string(value) == "new" {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("expected the local copy to be invalidated, got %q, %v", value, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// local copies never outlive redis.
+	a.Set(ctx, "short", []byte("value"), 50*time.Millisecond)
+	b.Get(ctx, "short")
+	if ttl := LocalTTL(b, "short"); ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Errorf("expected the local copy to expire with redis, expires in %s", ttl)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := b.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the entry to have expired, got %v", err)
+	}
+
+	a.Delete(ctx, "key")
+	if _, err := a.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the entry to be deleted, got %v", err)
+	}
+
+	for _, store := range []*TieredStore{a, b} {
+		if err := store.Close(); err != nil {
+			t.Errorf("expected to stop listening for invalidations, got %v", err)
+		}
+	}
+}
+
+type (
+	// fakeRedis serves just enough of the redis protocol for a TieredStore.
+	fakeRedis struct {
+		addr string
+
+		mu     sync.Mutex
+		values map[string]fakeValue
+		subs   map[string][]*fakeConn
+	}
+	fakeValue struct {
+		value   string
+		expires time.Time
+	}
+	fakeConn struct {
+		mu sync.Mutex
+		w  *bufio.Writer
+	}
+)
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	f := &fakeRedis{addr: l.Addr().String(), values: map[string]fakeValue{}, subs: map[string][]*fakeConn{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) client(t *testing.T) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{Addr: f.addr, Protocol: 2, DisableIndentity: true})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func (f *fakeRedis) waitForSubscribers(t *testing.T, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.Lock()
+		subscribed := 0
+		for _, conns := range f.subs {
+			subscribed += len(conns)
+		}
+		f.mu.Unlock()
+		if subscribed >= n {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", n, subscribed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	c := &fakeConn{w: bufio.NewWriter(conn)}
+	defer f.unsubscribe(c)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		reply := f.do(c, strings.ToUpper(args[0]), args[1:])
+		c.mu.Lock()
+		c.w.WriteString(reply)
+		c.w.Flush()
+		c.mu.Unlock()
+	}
+}
+
+func (f *fakeRedis) do(c *fakeConn, cmd string, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if v, ok := f.get(args[0]); ok {
+			return bulk(v.value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		v := fakeValue{value: args[1]}
+		if len(args) == 4 {
+			n, _ := strconv.Atoi(args[3])
+			unit := time.Second
+			if strings.EqualFold(args[2], "px") {
+				unit = time.Millisecond
+			}
+			v.expires = time.Now().Add(time.Duration(n) * unit)
+		}
+		f.values[args[0]] = v
+		return "+OK\r\n"
+	case "PTTL":
+		v, ok := f.get(args[0])
+		switch {
+		case !ok:
+			return ":-2\r\n"
+		case v.expires.IsZero():
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(v.expires).Milliseconds())
+	case "DEL":
+		for _, key := range args {
+			delete(f.values, key)
+		}
+		return fmt.Sprintf(":%d\r\n", len(args))
+	case "SUBSCRIBE":
+		f.subs[args[0]] = append(f.subs[args[0]], c)
+		return "*3\r\n" + bulk("subscribe") + bulk(args[0]) + ":1\r\n"
+	case "PUBLISH":
+		message := "*3\r\n" + bulk("message") + bulk(args[0]) + bulk(args[1])
+		for _, sub := range f.subs[args[0]] {
+			if sub != c {
+				sub.mu.Lock()
+				sub.w.WriteString(message)
+				sub.w.Flush()
+				sub.mu.Unlock()
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", len(f.subs[args[0]]))
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd)
+}
+
+func (f *fakeRedis) get(key string) (fakeValue, bool) {
+	v, ok := f.values[key]
+	if ok && !v.expires.IsZero() && !time.Now().Before(v.expires) {
+		delete(f.values, key)
+		return v, false
+	}
+	return v, ok
+}
+
+func (f *fakeRedis) unsubscribe(c *fakeConn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for channel, conns := range f.subs {
+		for i, sub := range conns {
+			if sub == c {
+				f.subs[channel] = append(conns[:i], conns[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected an array, got %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		arg := make([]byte, size+2)
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
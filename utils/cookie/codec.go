@@ -0,0 +1,137 @@
+package cookie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+)
+
+var (
+	ErrInvalid = errors.New("cookie: invalid value")
+	ErrExpired = errors.New("cookie: expired")
+)
+
+// Codec reads and writes a cookie holding a T. Values are encoded with
+// bimarshal, and signed or encrypted with the application keys, so they can
+// be trusted when they are read back.
+//
+// The expiry is kept inside the protected payload as well as on the cookie,
+// since a client is free to ignore the cookie's Max-Age.
+type Codec[T any] struct {
+	cookie http.Cookie
+	enc    func(data *T) bimarshal.Bimarshal
+	seal   func(purpose string, b []byte) (string, error)
+	open   func(purpose string, value string) ([]byte, error)
+	now    func() time.Time
+}
+
+// Signed cookies can be read, but not changed, by the client.
+func Signed[T any](c http.Cookie, keys *symetric.Keyring, enc func(data *T) bimarshal.Bimarshal) *Codec[T] {
+	return &Codec[T]{
+		cookie: c,
+		enc:    enc,
+		seal:   func(purpose string, b []byte) (string, error) { return keys.Sign(purpose, b), nil },
+		open:   keys.Verify,
+		now:    time.Now,
+	}
+}
+
+// Encrypted cookies can't be read or changed by the client.
+func Encrypted[T any](c http.Cookie, keys *symetric.Keyring, enc func(data *T) bimarshal.Bimarshal) *Codec[T] {
+	return &Codec[T]{
+		cookie: c,
+		enc:    enc,
+		seal:   keys.Encrypt,
+		open:   keys.Decrypt,
+		now:    time.Now,
+	}
+}
+
+// the cookie name is part of the purpose, so a value can't be moved to
+// another cookie.
+func (c *Codec[T]) purpose() string { return "cookie/" + c.cookie.Name }
+
+func (c *Codec[T]) Name() string { return c.cookie.Name }
+
+// WithMaxAge returns a copy of c that writes cookies lasting maxAge, instead
+// of the cookie's default.
+func (c *Codec[T]) WithMaxAge(maxAge time.Duration) *Codec[T] {
+	cp := *c
+	cp.cookie.MaxAge = int(maxAge.Seconds())
+	return &cp
+}
+
+// Encode builds the cookie holding value.
+func (c *Codec[T]) Encode(value *T) (*http.Cookie, error) {
+	b, err := c.enc(value).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	// the payload is prefixed with the expiry in unix seconds, or zero for
+	// cookies that last until the browser is closed.
+	var expires int64
+	if c.cookie.MaxAge > 0 {
+		expires = c.now().Add(time.Duration(c.cookie.MaxAge) * time.Second).Unix()
+	}
+	payload := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(b)), uint64(expires))
+	payload = append(payload, b...)
+
+	sealed, err := c.seal(c.purpose(), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := c.cookie
+	cookie.Value = sealed
+	return &cookie, nil
+}
+
+// Decode reads the value of a cookie written by Encode.
+func (c *Codec[T]) Decode(value string) (*T, error) {
+	payload, err := c.open(c.purpose(), value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	} else if len(payload) < 8 {
+		return nil, ErrInvalid
+	}
+
+	if expires := int64(binary.BigEndian.Uint64(payload)); expires != 0 && c.now().Unix() >= expires {
+		return nil, ErrExpired
+	}
+
+	data := new(T)
+	if err := c.enc(data).UnmarshalBinary(payload[8:]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return data, nil
+}
+
+// Set writes the cookie holding value to the response.
+func (c *Codec[T]) Set(w http.ResponseWriter, value *T) error {
+	cookie, err := c.Encode(value)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// Get reads the cookie from the request. It returns http.ErrNoCookie when
+// the request doesn't have one, and ErrInvalid or ErrExpired when it can't be
+// trusted.
+func (c *Codec[T]) Get(r *http.Request) (*T, error) {
+	cookie, err := r.Cookie(c.cookie.Name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decode(cookie.Value)
+}
+
+// Delete removes the cookie from the client.
+func (c *Codec[T]) Delete(w http.ResponseWriter) { Delete(w, c.cookie) }
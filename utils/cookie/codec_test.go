@@ -0,0 +1,107 @@
+package cookie_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/cookie"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+)
+
+const secret = "a very long application secret used for testing"
+
+func keyring(t *testing.T) *symetric.Keyring {
+	k, err := symetric.NewKeyring(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// roundTrip writes value with c, and reads it back from a new request.
+func roundTrip[T any](t *testing.T, c *Codec[T], value *T) (*T, error) {
+	w := httptest.NewRecorder()
+	if err := c.Set(w, value); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return c.Get(r)
+}
+
+func TestSigned(t *testing.T) {
+	c := NewSession(keyring(t))
+
+	value, err := roundTrip(t, c, &SessionValue{ID: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	} else if value.ID != "abc" {
+		t.Errorf("expected %q, got %q", "abc", value.ID)
+	}
+
+	// signed cookies can't be moved to another cookie
+	state := Signed[SessionValue](State, keyring(t), nil)
+	cookie, _ := c.Encode(&SessionValue{ID: "abc"})
+	if _, err := state.Decode(cookie.Value); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	c := NewState(keyring(t))
+
+	cookie, err := c.Encode(&StateValue{State: "a secret state"})
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(cookie.Value, "secret") {
+		t.Errorf("expected the value to be encrypted, got %q", cookie.Value)
+	} else if cookie.Name != State.Name || cookie.MaxAge != State.MaxAge {
+		t.Errorf("expected the State cookie, got %+v", cookie)
+	}
+
+	value, err := c.Decode(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	} else if value.State != "a secret state" {
+		t.Errorf("expected %q, got %q", "a secret state", value.State)
+	}
+
+	if _, err := c.Decode(cookie.Value[:len(cookie.Value)-4]); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for a tampered value, got %v", err)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	now := time.Now()
+	c := NewSession(keyring(t)).WithMaxAge(time.Minute).WithClock(func() time.Time { return now })
+
+	cookie, _ := c.Encode(&SessionValue{ID: "abc"})
+	if cookie.MaxAge != 60 {
+		t.Errorf("expected max age 60, got %d", cookie.MaxAge)
+	}
+
+	now = now.Add(59 * time.Second)
+	if _, err := c.Decode(cookie.Value); err != nil {
+		t.Errorf("expected cookie to be valid, got %v", err)
+	}
+
+	// the client kept the cookie longer than it was told to
+	now = now.Add(time.Second)
+	if _, err := c.Decode(cookie.Value); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := NewSession(keyring(t)).Get(r); !errors.Is(err, http.ErrNoCookie) {
+		t.Errorf("expected http.ErrNoCookie, got %v", err)
+	}
+}
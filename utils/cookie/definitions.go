@@ -1,6 +1,11 @@
 package cookie
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/Gardego5/garrettdavis.dev/utils/bimarshal"
+	"github.com/Gardego5/garrettdavis.dev/utils/symetric"
+)
 
 var (
 	State = http.Cookie{
@@ -20,3 +25,38 @@ var (
 		SameSite: http.SameSiteLaxMode,
 	}
 )
+
+type (
+	// StateValue is held by the State cookie while signing in.
+	StateValue struct {
+		// Provider is the name of the oauth provider being signed in with.
+		Provider string `json:"provider"`
+		// State is sent to the provider, and must come back unchanged.
+		State string `json:"state"`
+		// Verifier is the pkce code verifier, only its challenge is sent to
+		// the provider before exchanging the code.
+		Verifier string `json:"verifier"`
+		// Nonce is sent to openid connect providers, and must be in the id
+		// token they issue.
+		Nonce string `json:"nonce"`
+		// Next is the local url to return to after signing in.
+		Next string `json:"next,omitempty"`
+	}
+
+	// SessionValue is held by the Session cookie.
+	SessionValue struct {
+		ID string `json:"id"`
+	}
+)
+
+// NewState is the codec for the State cookie. It is encrypted, since it holds
+// what we expect back from the oauth provider.
+func NewState(keys *symetric.Keyring) *Codec[StateValue] {
+	return Encrypted[StateValue](State, keys, bimarshal.JSON)
+}
+
+// NewSession is the codec for the Session cookie. It is signed, so that
+// clients can't choose their own session id.
+func NewSession(keys *symetric.Keyring) *Codec[SessionValue] {
+	return Signed[SessionValue](Session, keys, bimarshal.JSON)
+}
@@ -0,0 +1,9 @@
+package cookie
+
+import "time"
+
+func (c *Codec[T]) WithClock(now func() time.Time) *Codec[T] {
+	cp := *c
+	cp.now = now
+	return &cp
+}
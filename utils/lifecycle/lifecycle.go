@@ -0,0 +1,179 @@
+// Package lifecycle starts and stops the parts of a program in order. Parts
+// are started in the order they're appended, and stopped in reverse, so
+// nothing is stopped while something started after it may still use it.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Hook starts and stops part of a program. Either may be nil. Start must not
+// block, things that run until they're stopped are started with Go.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+type Lifecycle struct {
+	logger *slog.Logger
+	hooks  []Hook
+	// started is how many hooks have been started.
+	started int
+
+	failed   chan error
+	stopping chan struct{}
+	stop     sync.Once
+}
+
+func New(logger *slog.Logger) *Lifecycle {
+	return &Lifecycle{
+		logger:   logger.With("scope", "lifecycle"),
+		failed:   make(chan error, 1),
+		stopping: make(chan struct{}),
+	}
+}
+
+// Append adds hook, to be started after every hook already appended.
+func (l *Lifecycle) Append(hook Hook) {
+	l.hooks = append(l.hooks, hook)
+}
+
+// Closer appends a hook that only stops, by calling close.
+func (l *Lifecycle) Closer(name string, close func() error) {
+	l.Append(Hook{Name: name, Stop: func(context.Context) error { return close() }})
+}
+
+// Go appends a hook that runs run in the background, until the context it's
+// given is cancelled when it's stopped. It's a failure for run to return
+// before then.
+func (l *Lifecycle) Go(name string, run func(ctx context.Context) error) {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+
+	l.Append(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				err := run(ctx)
+				if ctx.Err() == nil {
+					if err == nil {
+						err = errors.New("returned before being stopped")
+					}
+					l.Fail(fmt.Errorf("%s: %w", name, err))
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// Serve appends a hook that serves server. It's listening by the time it's
+// started, so it's an error to start if it can't listen. When it's stopped,
+// it keeps serving for drain, so that load balancers notice it's stopping,
+// then stops accepting requests and waits for the ones in flight.
+func (l *Lifecycle) Serve(name string, server *http.Server, drain time.Duration) {
+	l.Append(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			l.logger.Info("listening", "hook", name, "addr", listener.Addr().String())
+			go func() {
+				if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+					l.Fail(fmt.Errorf("%s: %w", name, err))
+				}
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			select {
+			case <-time.After(drain):
+			case <-ctx.Done():
+			}
+			return server.Shutdown(ctx)
+		},
+	})
+}
+
+// Fail reports that part of the program has failed while running, which
+// ends Wait. Only the first failure is kept.
+func (l *Lifecycle) Fail(err error) {
+	select {
+	case l.failed <- err:
+	default:
+	}
+}
+
+// Stopping is closed when the program starts to stop.
+func (l *Lifecycle) Stopping() <-chan struct{} {
+	return l.stopping
+}
+
+// Start starts every hook in order. If one fails, the ones already started
+// are stopped.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	for _, hook := range l.hooks[l.started:] {
+		if hook.Start != nil {
+			l.logger.InfoContext(ctx, "starting", "hook", hook.Name)
+			if err := hook.Start(ctx); err != nil {
+				err = fmt.Errorf("starting %s: %w", hook.Name, err)
+				return errors.Join(err, l.Stop(ctx))
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Wait blocks until ctx is done, usually because the program was signalled
+// to stop, or part of the program fails.
+func (l *Lifecycle) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-l.failed:
+		return err
+	}
+}
+
+// Stop stops every hook that was started, in reverse. Every hook is stopped
+// even if some fail, but they all share ctx's deadline.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.stop.Do(func() { close(l.stopping) })
+
+	var errs []error
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.Stop == nil {
+			continue
+		}
+		l.logger.InfoContext(ctx, "stopping", "hook", hook.Name)
+		if err := hook.Stop(ctx); err != nil {
+			l.logger.ErrorContext(ctx, "error stopping", "hook", hook.Name, "error", err)
+			errs = append(errs, fmt.Errorf("stopping %s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/lifecycle"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestOrder(t *testing.T) {
+	l, events := New(logger), []string{}
+	for _, name := range []string{"db", "cache", "server"} {
+		l.Append(Hook{
+			Name:  name,
+			Start: func(context.Context) error { events = append(events, "start "+name); return nil },
+			Stop:  func(context.Context) error { events = append(events, "stop "+name); return nil },
+		})
+	}
+
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	select {
+	case <-l.Stopping():
+		t.Fatal("expected not to be stopping yet")
+	default:
+	}
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatalf("error stopping: %v", err)
+	}
+	<-l.Stopping()
+
+	expected := []string{"start db", "start cache", "start server", "stop server", "stop cache", "stop db"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	l, events := New(logger), []string{}
+	l.Closer("db", func() error { events = append(events, "stop db"); return nil })
+	l.Append(Hook{Name: "server", Start: func(context.Context) error { return errors.New("no port") }})
+	l.Closer("never", func() error { events = append(events, "stop never"); return nil })
+
+	if err := l.Start(context.Background()); err == nil {
+		t.Fatal("expected an error starting")
+	}
+	if !reflect.DeepEqual(events, []string{"stop db"}) {
+		t.Errorf("expected only what started to stop, got %v", events)
+	}
+}
+
+func TestGo(t *testing.T) {
+	l, stopped := New(logger), make(chan struct{})
+	l.Go("loop", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	})
+	l.Go("broken", func(ctx context.Context) error { return errors.New("broken") })
+
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	if err := l.Wait(context.Background()); err == nil || err.Error() != "broken: broken" {
+		t.Errorf("expected the broken loop to end Wait, got %v", err)
+	}
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatalf("error stopping: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the loop to have stopped")
+	}
+}
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	l, started := New(logger), make(chan struct{})
+	l.Serve("server", &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}, 10*time.Millisecond)
+
+	if err := l.Start(context.Background()); err == nil {
+		t.Fatal("expected an error starting while the address is in use")
+	}
+	listener.Close()
+	if err := l.Start(context.Background()); err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+
+	body := make(chan string)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	if err := l.Stop(context.Background()); err != nil {
+		t.Fatalf("error stopping: %v", err)
+	}
+	if b := <-body; b != "done" {
+		t.Errorf("expected the request in flight to finish, got %q", b)
+	}
+	if _, err := http.Get("http://" + addr); err == nil {
+		t.Error("expected the server to have stopped")
+	}
+}
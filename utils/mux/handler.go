@@ -0,0 +1,85 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type (
+	// HandlerFunc is a handler that fails by returning an error, rather than
+	// answering with it. Errors are answered by the ErrorHandler given to
+	// OnError, so a handler must not have written anything before it fails.
+	HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+	// ErrorHandler answers a request that failed with err.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// Error is an error a request fails with, which is answered with Status
+	// and Message, fit to be shown to whoever made the request. Err is what
+	// caused it, which is only logged.
+	Error struct {
+		Status  int
+		Message string
+		// Fields describes what's wrong with each invalid field of a request.
+		Fields map[string]string
+		Err    error
+	}
+
+	errorHandlerKey struct{}
+)
+
+var _ http.Handler = HandlerFunc(nil)
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		handler, ok := r.Context().Value(errorHandlerKey{}).(ErrorHandler)
+		if !ok {
+			handler = defaultErrorHandler
+		}
+		handler(w, r, err)
+	}
+}
+
+// OnError is a middleware that has errors returned by a HandlerFunc answered
+// by handler. Without it they're answered with plain text.
+func OnError(handler ErrorHandler) Middleware {
+	return MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorHandlerKey{}, handler)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
+
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	e := AsError(err)
+	http.Error(w, e.Message, e.Status)
+}
+
+// NewError is an error a request fails with, caused by err, which may be nil.
+func NewError(status int, message string, err error) *Error {
+	return &Error{Status: status, Message: message, Err: err}
+}
+
+// AsError is err as an Error. Errors that aren't one are unexpected, and
+// become an internal server error that doesn't say what happened.
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Status: http.StatusInternalServerError, Message: "An error has occurred.", Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Status, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
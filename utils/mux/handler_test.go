@@ -0,0 +1,54 @@
+package mux_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/mux"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	var handled error
+	m := NewServeMux(func(m *ServeMux) {
+		m.Handle("GET /missing", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return fmt.Errorf("looking: %w", NewError(http.StatusNotFound, "There's nothing here.", nil))
+		}))
+		m.Handle("GET /broken", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("broken")
+		}))
+		m.Handle("GET /handled", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("handled")
+		}), OnError(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.WriteHeader(AsError(err).Status)
+		}))
+	})
+
+	for _, test := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/missing", http.StatusNotFound, "There's nothing here."},
+		// unexpected errors don't say what happened.
+		{"/broken", http.StatusInternalServerError, "An error has occurred."},
+		{"/handled", http.StatusInternalServerError, ""},
+	} {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.path, test.status, w.Code)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.path, test.body, body)
+		}
+	}
+
+	if handled == nil || handled.Error() != "handled" {
+		t.Errorf("expected the error to be given to the handler, got %v", handled)
+	}
+}
@@ -0,0 +1,261 @@
+// Package openapi describes an http api as an OpenAPI 3.1 document. Schemas
+// are generated from the go types the api sends and receives, using their
+// json tags for names, and their validate tags for constraints.
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Version = "3.1.0"
+
+type (
+	Document struct {
+		OpenAPI    string              `json:"openapi"`
+		Info       Info                `json:"info"`
+		Servers    []Server            `json:"servers,omitempty"`
+		Paths      map[string]PathItem `json:"paths"`
+		Components Components          `json:"components"`
+		names      map[reflect.Type]string
+		// Name is the component name of a named type. It's the name of the
+		// type when it's nil.
+		Name func(reflect.Type) string `json:"-"`
+	}
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+	}
+	Server struct {
+		URL string `json:"url"`
+	}
+	// PathItem is the operations of a path, by lowercase method.
+	PathItem  map[string]*Operation
+	Operation struct {
+		OperationID string                `json:"operationId"`
+		Summary     string                `json:"summary,omitempty"`
+		Tags        []string              `json:"tags,omitempty"`
+		Parameters  []Parameter           `json:"parameters,omitempty"`
+		RequestBody *RequestBody          `json:"requestBody,omitempty"`
+		Responses   map[string]Response   `json:"responses"`
+		Security    []map[string][]string `json:"security,omitempty"`
+	}
+	Parameter struct {
+		Name     string  `json:"name"`
+		In       string  `json:"in"`
+		Required bool    `json:"required,omitempty"`
+		Schema   *Schema `json:"schema"`
+	}
+	RequestBody struct {
+		Required bool                 `json:"required,omitempty"`
+		Content  map[string]MediaType `json:"content"`
+	}
+	Response struct {
+		Description string               `json:"description"`
+		Content     map[string]MediaType `json:"content,omitempty"`
+	}
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+	Components struct {
+		Schemas         map[string]*Schema        `json:"schemas"`
+		SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+	SecurityScheme struct {
+		Type        string `json:"type"`
+		Scheme      string `json:"scheme,omitempty"`
+		Description string `json:"description,omitempty"`
+	}
+	Schema struct {
+		Ref        string             `json:"$ref,omitempty"`
+		Type       string             `json:"type,omitempty"`
+		Format     string             `json:"format,omitempty"`
+		Enum       []string           `json:"enum,omitempty"`
+		Minimum    *float64           `json:"minimum,omitempty"`
+		Maximum    *float64           `json:"maximum,omitempty"`
+		MinLength  *int               `json:"minLength,omitempty"`
+		MaxLength  *int               `json:"maxLength,omitempty"`
+		Items      *Schema            `json:"items,omitempty"`
+		Properties map[string]*Schema `json:"properties,omitempty"`
+		Required   []string           `json:"required,omitempty"`
+		// AdditionalProperties is the schema of the values of a map.
+		AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	}
+)
+
+func New(info Info, servers ...Server) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Servers:    servers,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// Add adds the operation done by method at path, which uses the same
+// "{param}" wildcards as http.ServeMux.
+func (d *Document) Add(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// JSON is the content of a request or response with a json body like v.
+func (d *Document) JSON(v any) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: d.Schema(v)}}
+}
+
+// Schema is the schema of v's type. Named structs are added to the
+// components, and referred to.
+func (d *Document) Schema(v any) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+// Parameters are the query parameters of v, a struct whose fields are
+// tagged with the name of their parameter.
+func (d *Document) Parameters(v any, tag string) []Parameter {
+	t := reflect.TypeOf(v)
+	params := []Parameter{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		schema := d.schema(field.Type)
+		constrain(schema, field.Tag.Get("validate"))
+		params = append(params, Parameter{Name: name, In: "query", Schema: schema})
+	}
+	return params
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		// generic types, like envelopes, are inlined. their names aren't
+		// fit for a component.
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return d.object(t)
+		}
+		name, ok := d.names[t]
+		if !ok {
+			name = t.Name()
+			if d.Name != nil {
+				name = d.Name(t)
+			}
+			d.names[t] = name
+			d.Components.Schemas[name] = d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// object is the schema of a struct. Fields are required unless they're
+// omitempty, and embedded structs are flattened, like encoding/json does.
+func (d *Document) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// the fields of embedded structs are promoted, even when the struct
+		// itself isn't exported.
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := d.object(field.Type)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		} else if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		prop := d.schema(field.Type)
+		constrain(prop, field.Tag.Get("validate"))
+		schema.Properties[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// constrain adds the constraints of validate tags that have an equivalent,
+// to schema. Constraints can't be added to a reference.
+func constrain(schema *Schema, validate string) {
+	if schema.Ref != "" || validate == "" {
+		return
+	}
+
+	for _, rule := range strings.Split(validate, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Fields(value)
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch schema.Type {
+			case "integer", "number":
+				if key == "min" {
+					schema.Minimum = &n
+				} else {
+					schema.Maximum = &n
+				}
+			case "string":
+				length := int(n)
+				if key == "min" {
+					schema.MinLength = &length
+				} else {
+					schema.MaxLength = &length
+				}
+			}
+		case "dive":
+			// rules after dive apply to the elements.
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/openapi"
+)
+
+type (
+	base struct {
+		ID int `json:"id"`
+	}
+	widget struct {
+		base
+		Name    string    `json:"name" validate:"required,max=10"`
+		Color   string    `json:"color,omitempty" validate:"oneof=red blue"`
+		Parts   []part    `json:"parts"`
+		Made    time.Time `json:"made"`
+		private string
+		Skipped string `json:"-"`
+	}
+	part struct {
+		Weight float64 `json:"weight" validate:"min=0"`
+	}
+	envelope[T any] struct {
+		Data T `json:"data"`
+	}
+)
+
+func TestSchema(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	if ref := doc.Schema(envelope[widget]{}); ref.Type != "object" || ref.Properties["data"].Ref != "#/components/schemas/widget" {
+		t.Fatalf("expected an inlined envelope referring to widget, got %+v", ref)
+	}
+
+	widget := doc.Components.Schemas["widget"]
+	if widget == nil {
+		t.Fatalf("expected widget in components, got %v", doc.Components.Schemas)
+	}
+	for name, expected := range map[string]string{
+		"id": "integer", "name": "string", "color": "string", "parts": "array", "made": "string",
+	} {
+		if prop := widget.Properties[name]; prop == nil || prop.Type != expected {
+			t.Errorf("expected property %s to be a %s, got %+v", name, expected, prop)
+		}
+	}
+	if len(widget.Properties) != 5 {
+		t.Errorf("expected 5 properties, got %d", len(widget.Properties))
+	}
+	if !reflect.DeepEqual(widget.Required, []string{"id", "name", "parts", "made"}) {
+		t.Errorf("unexpected required properties %v", widget.Required)
+	}
+	if name := widget.Properties["name"]; name.MaxLength == nil || *name.MaxLength != 10 {
+		t.Errorf("expected name to have a max length of 10, got %+v", name)
+	}
+	if color := widget.Properties["color"]; !reflect.DeepEqual(color.Enum, []string{"red", "blue"}) {
+		t.Errorf("expected color to be an enum, got %+v", color)
+	}
+	if made := widget.Properties["made"]; made.Format != "date-time" {
+		t.Errorf("expected made to be a date-time, got %+v", made)
+	}
+	if items := widget.Properties["parts"].Items; items.Ref != "#/components/schemas/part" {
+		t.Errorf("expected parts to refer to part, got %+v", items)
+	}
+	if weight := doc.Components.Schemas["part"].Properties["weight"]; weight.Minimum == nil || *weight.Minimum != 0 {
+		t.Errorf("expected weight to have a minimum of 0, got %+v", weight)
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("error marshalling document: %v", err)
+	}
+}
+
+func TestParameters(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	params := doc.Parameters(struct {
+		Sort   string `q:"sort" validate:"oneof=ASC DESC"`
+		Limit  int    `q:"limit" validate:"min=1,max=100"`
+		Ignore int
+	}{}, "q")
+
+	if len(params) != 2 {
+		t.Fatalf("expected 2 parameters, got %+v", params)
+	}
+	if params[0].Name != "sort" || params[0].In != "query" || !reflect.DeepEqual(params[0].Schema.Enum, []string{"ASC", "DESC"}) {
+		t.Errorf("unexpected sort parameter %+v", params[0])
+	}
+	if limit := params[1].Schema; *limit.Minimum != 1 || *limit.Maximum != 100 {
+		t.Errorf("unexpected limit parameter %+v", limit)
+	}
+}
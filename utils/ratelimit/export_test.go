@@ -0,0 +1,7 @@
+package ratelimit
+
+import "time"
+
+func NewMemoryWithClock(now func() time.Time) Limiter {
+	return newMemory(now)
+}
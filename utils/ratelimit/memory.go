@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryLimiter keeps limits in process, so they're per machine.
+type memoryLimiter struct {
+	mu    sync.Mutex
+	tats  map[string]time.Time
+	swept time.Time
+	now   func() time.Time
+}
+
+var _ Limiter = (*memoryLimiter)(nil)
+
+// sweepEvery is how often keys whose limits have fully recovered are
+// forgotten.
+const sweepEvery = time.Minute
+
+func NewMemory() Limiter { return newMemory(time.Now) }
+
+func newMemory(now func() time.Time) *memoryLimiter {
+	return &memoryLimiter{tats: map[string]time.Time{}, swept: now(), now: now}
+}
+
+func (m *memoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := limit.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.swept) >= sweepEvery {
+		for k, tat := range m.tats {
+			if !tat.After(now) {
+				delete(m.tats, k)
+			}
+		}
+		m.swept = now
+	}
+
+	res, tat := gcra(now, m.tats[key], limit)
+	if res.Allowed {
+		m.tats[key] = tat
+	}
+	return res, nil
+}
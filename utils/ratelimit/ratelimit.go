@@ -0,0 +1,99 @@
+// Package ratelimit limits how often something may happen, using the generic
+// cell rate algorithm. Each key only needs to store the time its next request
+// would be allowed if it had no burst, so limits are cheap to keep in redis,
+// and requests are spread out rather than allowed in bursts at the start of
+// every window.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("ratelimit: invalid limit")
+
+type (
+	// Limit allows Rate requests every Period, up to Burst of them at once.
+	Limit struct {
+		Rate   int
+		Period time.Duration
+		Burst  int
+	}
+
+	// Result is whether a request was allowed, and what's left of its limit.
+	Result struct {
+		Allowed bool
+		// Remaining is how many more requests would be allowed right away.
+		Remaining int
+		// RetryAfter is how long until a request that wasn't allowed would
+		// be.
+		RetryAfter time.Duration
+	}
+
+	Limiter interface {
+		// Allow counts a request by key against limit.
+		Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	}
+)
+
+// PerMinute allows rate requests a minute, up to burst at once.
+func PerMinute(rate, burst int) Limit {
+	return Limit{Rate: rate, Period: time.Minute, Burst: burst}
+}
+
+// Validate checks that limit allows some requests. A zero rate or period
+// would never allow one, and a zero burst would refuse every one.
+func (l Limit) Validate() error {
+	switch {
+	case l.Rate <= 0 || l.Period <= 0:
+		return fmt.Errorf("%w: the rate and period must be positive, got %d every %s", ErrInvalidLimit, l.Rate, l.Period)
+	case l.interval() <= 0:
+		return fmt.Errorf("%w: %d requests every %s is too many", ErrInvalidLimit, l.Rate, l.Period)
+	case l.Burst <= 0:
+		return fmt.Errorf("%w: the burst must be positive, got %d", ErrInvalidLimit, l.Burst)
+	}
+	return nil
+}
+
+// interval is how often a request is allowed, once the burst is used up.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// gcra counts a request made at now against limit, where tat is the time the
+// next request would've been allowed without a burst. It returns the new tat
+// when the request is allowed.
+func gcra(now, tat time.Time, limit Limit) (Result, time.Time) {
+	interval := limit.interval()
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	allowAt := next.Add(-interval * time.Duration(limit.Burst))
+	if now.Before(allowAt) {
+		return Result{RetryAfter: allowAt.Sub(now)}, tat
+	}
+	return Result{Allowed: true, Remaining: int(now.Sub(allowAt) / interval)}, next
+}
+
+type fallback struct {
+	primary, secondary Limiter
+	onError            func(error)
+}
+
+// Fallback counts requests with primary, unless it fails, when they're counted
+// with secondary instead, after telling onError why.
+func Fallback(primary, secondary Limiter, onError func(error)) Limiter {
+	return &fallback{primary, secondary, onError}
+}
+
+func (f *fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := f.primary.Allow(ctx, key, limit)
+	if err == nil {
+		return res, nil
+	}
+	f.onError(err)
+	return f.secondary.Allow(ctx, key, limit)
+}
@@ -0,0 +1,87 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/ratelimit"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	limiter := NewMemoryWithClock(func() time.Time { return now })
+	limit := PerMinute(6, 3)
+
+	// the burst is allowed at once.
+	for i, remaining := range []int{2, 1, 0} {
+		res, _ := limiter.Allow(ctx, "a", limit)
+		if !res.Allowed || res.Remaining != remaining {
+			t.Fatalf("request %d: expected to be allowed with %d remaining, got %+v", i, remaining, res)
+		}
+	}
+
+	res, _ := limiter.Allow(ctx, "a", limit)
+	if res.Allowed || res.RetryAfter != 10*time.Second {
+		t.Fatalf("expected to retry after 10s, got %+v", res)
+	}
+	if res, _ := limiter.Allow(ctx, "b", limit); !res.Allowed {
+		t.Errorf("expected other keys to have their own limit, got %+v", res)
+	}
+
+	// then one more every interval.
+	now = now.Add(10 * time.Second)
+	if res, _ := limiter.Allow(ctx, "a", limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected to be allowed after the interval, got %+v", res)
+	}
+	if res, _ := limiter.Allow(ctx, "a", limit); res.Allowed {
+		t.Errorf("expected only one request after the interval, got %+v", res)
+	}
+
+	// until the whole burst has recovered.
+	now = now.Add(time.Hour)
+	if res, _ := limiter.Allow(ctx, "a", limit); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected the burst to have recovered, got %+v", res)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, limit := range []Limit{
+		PerMinute(0, 1),
+		PerMinute(-1, 1),
+		PerMinute(1, 0),
+		{Rate: 1, Burst: 1},
+		{Rate: 10, Period: time.Nanosecond, Burst: 1},
+	} {
+		if err := limit.Validate(); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("%+v: expected ErrInvalidLimit, got %v", limit, err)
+		}
+		if _, err := NewMemory().Allow(context.Background(), "a", limit); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("%+v: expected the limiter to refuse it, got %v", limit, err)
+		}
+	}
+	if err := PerMinute(1, 1).Validate(); err != nil {
+		t.Errorf("expected a valid limit, got %v", err)
+	}
+}
+
+type failing struct{}
+
+func (failing) Allow(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("unavailable")
+}
+
+func TestFallback(t *testing.T) {
+	var reported error
+	limiter := Fallback(failing{}, NewMemory(), func(err error) { reported = err })
+
+	res, err := limiter.Allow(context.Background(), "a", PerMinute(1, 1))
+	if err != nil || !res.Allowed {
+		t.Errorf("expected the fallback to allow the request, got %+v, %v", res, err)
+	}
+	if reported == nil {
+		t.Error("expected the error to be reported")
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLimiter keeps limits in redis, so they're shared by every machine.
+type redisLimiter struct{ rdb *redis.Client }
+
+var _ Limiter = (*redisLimiter)(nil)
+
+func NewRedis(rdb *redis.Client) Limiter { return &redisLimiter{rdb} }
+
+// allowScript is gcra, run in redis so that requests to every machine are
+// counted atomically, against redis' clock. Times are in milliseconds, and
+// keys expire once their limit has fully recovered.
+var allowScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+if now < allow_at then
+	return {0, 0, allow_at - now}
+end
+
+redis.call("SET", KEYS[1], string.format("%d", new_tat), "PX", new_tat - now)
+return {1, math.floor((now - allow_at) / interval), 0}
+`)
+
+func (r *redisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := limit.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	values, err := allowScript.Run(ctx, r.rdb, []string{key},
+		max(limit.interval().Milliseconds(), 1), limit.Burst).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ClientIP is the address of the client that sent r. Behind fly's proxy the
+// connection comes from the proxy, so middleware.ClientIP replaces it with
+// the address the proxy forwards.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// LocalURL returns target relative to this site, if it points to this site:
+// either an absolute path, or an absolute url with the same origin as
+// baseUrl. Anything else is rejected, so the result is safe to redirect to.
+func LocalURL(target, baseUrl string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || target == "" {
+		return "", false
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		base, err := url.Parse(baseUrl)
+		if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			return "", false
+		}
+	}
+
+	// browsers treat backslashes like slashes, so "/\example.com" is as
+	// protocol relative as "//example.com".
+	if !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") || strings.Contains(u.Path, `\`) {
+		return "", false
+	}
+
+	local := url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery, Fragment: u.Fragment}
+	return local.String(), true
+}
+
+// Accepts reports whether mediaType is acceptable to a client that sent the
+// Accept header accept. The most specific range matching mediaType decides,
+// and a quality of 0 means it isn't acceptable. Clients that don't send the
+// header accept anything.
+func Accepts(accept, mediaType string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	specificity, quality := -1, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch rtyp, rsubtype, _ := strings.Cut(rng, "/"); {
+		case rtyp == typ && rsubtype == subtype:
+			s = 2
+		case rtyp == typ && rsubtype == "*":
+			s = 1
+		case rtyp == "*" && rsubtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+
+		specificity, quality = s, 1
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				quality = 0
+			}
+		}
+	}
+	return quality > 0
+}
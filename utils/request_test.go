@@ -0,0 +1,51 @@
+package utils_test
+
+import (
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils"
+)
+
+func TestLocalURL(t *testing.T) {
+	const baseUrl = "https://garrettdavis.dev"
+
+	for target, expected := range map[string]string{
+		"/admin/user":                         "/admin/user",
+		"/admin/sessions?page=2#top":          "/admin/sessions?page=2#top",
+		"https://garrettdavis.dev/admin/user": "/admin/user",
+		"https://garrettdavis.dev":            "",
+		"http://garrettdavis.dev/admin/user":  "",
+		"https://example.com/admin/user":      "",
+		"//example.com/admin/user":            "",
+		`/\example.com/admin/user`:            "",
+		"javascript:alert(1)":                 "",
+		"admin/user":                          "",
+		"":                                    "",
+	} {
+		local, ok := LocalURL(target, baseUrl)
+		if ok != (expected != "") || local != expected {
+			t.Errorf("LocalURL(%q) = %q, %v, expected %q", target, local, ok, expected)
+		}
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                  true,
+		"application/json":                  true,
+		"application/*":                     true,
+		"*/*":                               true,
+		"text/html":                         false,
+		"text/html, application/json;q=0.5": true,
+		"text/html, */*;q=0.1":              true,
+		"text/html, application/json;q=0":   false,
+		"application/json;q=0, */*":         false,
+		"*/*;q=0, application/json":         true,
+		"APPLICATION/JSON":                  true,
+		"not a media type":                  false,
+	} {
+		if accepts := Accepts(accept, "application/json"); accepts != expected {
+			t.Errorf("Accepts(%q, application/json) = %v, expected %v", accept, accepts, expected)
+		}
+	}
+}
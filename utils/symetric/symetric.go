@@ -1,63 +1,232 @@
 package symetric
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"io"
 	"log/slog"
-	"os"
-	"strings"
+
+	"golang.org/x/crypto/hkdf"
 )
 
-const blockSize = 16
+// Encrypted values are formatted as:
+//
+//	version 1 byte (currently 1)
+//	nonce   12 bytes
+//	key id  4 bytes
+//	ciphertext & tag
+//
+// and then encoded as url safe base64 without padding. The key id comes from
+// the AEAD of the purpose, which prefixes it to what it seals.
+const version byte = 1
 
-func Block(s string) cipher.Block {
-	secret := make([]byte, blockSize)
-	_, err := strings.NewReader(s).Read(secret)
-	if err != nil {
-		slog.Error("Error reading application secret", "error", err)
-		os.Exit(1)
+const (
+	keySize   = 32 // AES-256
+	keyIdSize = 4
+	macSize   = sha256.Size
+	// secrets shorter than this are accepted, but aren't a good idea.
+	minSecretSize = 32
+)
+
+var (
+	ErrNoSecrets   = errors.New("symetric: at least one secret is required")
+	ErrMalformed   = errors.New("symetric: malformed ciphertext")
+	ErrUnknownKey  = errors.New("symetric: encrypted with an unknown key")
+	ErrDecryption  = errors.New("symetric: message authentication failed")
+	ErrSignature   = errors.New("symetric: invalid signature")
+	ErrUnsupported = errors.New("symetric: unsupported ciphertext version")
+)
+
+// Keyring derives encryption keys from application secrets. The first secret
+// is used to encrypt, and every secret is tried to decrypt, so that a secret
+// can be rotated by putting the new one first and removing the old one once
+// everything encrypted with it has expired.
+type Keyring struct{ secrets [][]byte }
+
+func NewKeyring(secrets ...string) (*Keyring, error) {
+	k := &Keyring{}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		} else if len(secret) < minSecretSize {
+			slog.Warn("application secret is shorter than recommended", "length", len(secret), "recommended", minSecretSize)
+		}
+		k.secrets = append(k.secrets, []byte(secret))
+	}
+	if len(k.secrets) == 0 {
+		return nil, ErrNoSecrets
 	}
+	return k, nil
+}
 
-	block, err := aes.NewCipher(secret)
-	if err != nil {
-		slog.Error("Error creating cipher block", "error", err)
-		os.Exit(1)
+// AEAD returns an AEAD keyed for purpose. Keys are derived separately for each
+// purpose, so a value encrypted for one purpose can't be decrypted as
+// another. Sealed values are prefixed with the id of the key that sealed them.
+func (k *Keyring) AEAD(purpose string) cipher.AEAD {
+	r := &ring{}
+	for _, secret := range k.secrets {
+		r.keys = append(r.keys, derive(secret, purpose))
+	}
+	return r
+}
+
+// Encrypt seals plaintext for purpose with the current key.
+func (k *Keyring) Encrypt(purpose string, plaintext []byte) (string, error) {
+	aead := k.AEAD(purpose)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
 	}
 
-	return block
+	header := append([]byte{version}, nonce...)
+	sealed := aead.Seal(header, nonce, plaintext, []byte{version})
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
 }
 
-func Encrypt[T ~string | ~[]byte](block cipher.Block, text T) (string, error) {
-	iv := make([]byte, blockSize)
-	_, err := rand.Read(iv)
+// Decrypt opens a value sealed by Encrypt for the same purpose, with any of
+// the keys in the keyring.
+func (k *Keyring) Decrypt(purpose string, ciphertext string) ([]byte, error) {
+	aead := k.AEAD(purpose)
+
+	sealed, err := base64.RawURLEncoding.DecodeString(ciphertext)
 	if err != nil {
-		return "", err
+		return nil, ErrMalformed
+	} else if len(sealed) < 1+aead.NonceSize() {
+		return nil, ErrMalformed
+	} else if sealed[0] != version {
+		return nil, ErrUnsupported
 	}
 
-	plainText := []byte(text)
-	cipherText := make([]byte, len(plainText))
-	cipher.NewCFBEncrypter(block, iv).
-		XORKeyStream(cipherText, plainText)
+	nonce, sealed := sealed[1:1+aead.NonceSize()], sealed[1+aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte{version})
+}
 
-	encrypted := make([]byte, 0, len(iv)+len(cipherText))
-	encrypted = append(encrypted, iv...)
-	encrypted = append(encrypted, cipherText...)
+// Sign authenticates message for purpose with the current key, without hiding
+// it. Signed values are formatted as:
+//
+//	version 1 byte (currently 1)
+//	key id  4 bytes
+//	message
+//	hmac    32 bytes (sha256 over everything before it)
+//
+// and then encoded as url safe base64 without padding.
+func (k *Keyring) Sign(purpose string, message []byte) string {
+	current := deriveMAC(k.secrets[0], purpose)
 
-	return base64.StdEncoding.EncodeToString(encrypted), nil
+	signed := append([]byte{version}, current.id...)
+	signed = append(signed, message...)
+	signed = current.sum(signed, signed)
+	return base64.RawURLEncoding.EncodeToString(signed)
 }
 
-func Decrypt[T ~string | ~[]byte](block cipher.Block, text T) (string, error) {
-	encrypted, err := base64.StdEncoding.DecodeString(string(text))
+// Verify checks a value signed by Sign for the same purpose, with any of the
+// keys in the keyring, and returns the message.
+func (k *Keyring) Verify(purpose string, signed string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(signed)
 	if err != nil {
-		return "", err
+		return nil, ErrMalformed
+	} else if len(b) < 1+keyIdSize+macSize {
+		return nil, ErrMalformed
+	} else if b[0] != version {
+		return nil, ErrUnsupported
+	}
+
+	id, body, mac := b[1:1+keyIdSize], b[:len(b)-macSize], b[len(b)-macSize:]
+	for _, secret := range k.secrets {
+		key := deriveMAC(secret, purpose)
+		if !bytes.Equal(key.id, id) {
+			continue
+		}
+		if !hmac.Equal(key.sum(nil, body), mac) {
+			return nil, ErrSignature
+		}
+		return body[1+keyIdSize:], nil
+	}
+	return nil, ErrUnknownKey
+}
+
+type (
+	macKey struct{ id, key []byte }
+	ring   struct{ keys []key }
+	key    struct {
+		id   []byte
+		aead cipher.AEAD
 	}
+)
+
+var _ cipher.AEAD = (*ring)(nil)
+
+func derive(secret []byte, purpose string) key {
+	kdf := hkdf.New(sha256.New, secret, nil, []byte("garrettdavis.dev/symetric/v1/"+purpose))
+
+	material := make([]byte, keySize+keyIdSize)
+	if _, err := io.ReadFull(kdf, material); err != nil {
+		panic(err) // only possible when reading more than hkdf can produce
+	}
+
+	block, err := aes.NewCipher(material[:keySize])
+	if err != nil {
+		panic(err) // only possible with an invalid key size
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err) // only possible with a non 128 bit block cipher
+	}
+
+	return key{id: material[keySize:], aead: aead}
+}
 
-	iv, cipherText := encrypted[:blockSize], encrypted[blockSize:]
-	plainText := make([]byte, len(cipherText))
-	cipher.NewCFBDecrypter(block, iv).
-		XORKeyStream(plainText, cipherText)
+// deriveMAC derives a signing key. The salt keeps signing keys independent of
+// the encryption keys derived for the same purpose.
+func deriveMAC(secret []byte, purpose string) macKey {
+	kdf := hkdf.New(sha256.New, secret, []byte("hmac-sha256"), []byte("garrettdavis.dev/symetric/v1/"+purpose))
 
-	return string(plainText), nil
+	material := make([]byte, macSize+keyIdSize)
+	if _, err := io.ReadFull(kdf, material); err != nil {
+		panic(err) // only possible when reading more than hkdf can produce
+	}
+
+	return macKey{id: material[macSize:], key: material[:macSize]}
+}
+
+// sum appends the mac of message to dst.
+func (k macKey) sum(dst, message []byte) []byte {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write(message)
+	return mac.Sum(dst)
+}
+
+func (r *ring) NonceSize() int { return r.keys[0].aead.NonceSize() }
+func (r *ring) Overhead() int  { return keyIdSize + r.keys[0].aead.Overhead() }
+
+func (r *ring) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	current := r.keys[0]
+	dst = append(dst, current.id...)
+	return current.aead.Seal(dst, nonce, plaintext, additionalData)
+}
+
+func (r *ring) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < keyIdSize {
+		return nil, ErrMalformed
+	}
+
+	id, ciphertext := ciphertext[:keyIdSize], ciphertext[keyIdSize:]
+	for _, k := range r.keys {
+		if !bytes.Equal(k.id, id) {
+			continue
+		}
+		plaintext, err := k.aead.Open(dst, nonce, ciphertext, additionalData)
+		if err != nil {
+			return nil, ErrDecryption
+		}
+		return plaintext, nil
+	}
+	return nil, ErrUnknownKey
 }
@@ -0,0 +1,122 @@
+package symetric_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/Gardego5/garrettdavis.dev/utils/symetric"
+)
+
+const (
+	secret      = "a very long application secret used for testing"
+	otherSecret = "another very long application secret for testing"
+)
+
+func mustKeyring(t *testing.T, secrets ...string) *Keyring {
+	k, err := NewKeyring(secrets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestRoundTrip(t *testing.T) {
+	k := mustKeyring(t, secret)
+
+	ciphertext, err := k.Encrypt("test", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(ciphertext, "+/=") {
+		t.Errorf("expected url safe ciphertext, got %q", ciphertext)
+	}
+
+	plaintext, err := k.Decrypt("test", ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "hello world" {
+		t.Errorf("expected %q, got %q", "hello world", plaintext)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	k := mustKeyring(t, secret)
+	ciphertext, _ := k.Encrypt("test", []byte("hello world"))
+
+	// flip a character in the ciphertext body
+	b := []byte(ciphertext)
+	i := len(b) - 5
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+
+	if _, err := k.Decrypt("test", string(b)); !errors.Is(err, ErrDecryption) {
+		t.Errorf("expected ErrDecryption for tampered ciphertext, got %v", err)
+	}
+	if _, err := k.Decrypt("other", ciphertext); err == nil {
+		t.Error("expected ciphertext not to decrypt for another purpose")
+	}
+	if _, err := k.Decrypt("test", "not base64!"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed, got %v", err)
+	}
+	if _, err := k.Decrypt("test", "AA"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for short ciphertext, got %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := mustKeyring(t, otherSecret)
+	rotated := mustKeyring(t, secret, otherSecret)
+	retired := mustKeyring(t, secret)
+
+	ciphertext, _ := old.Encrypt("test", []byte("hello world"))
+
+	if plaintext, err := rotated.Decrypt("test", ciphertext); err != nil || string(plaintext) != "hello world" {
+		t.Errorf("expected rotated keyring to decrypt with previous secret, got %q, %v", plaintext, err)
+	}
+	if _, err := retired.Decrypt("test", ciphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey once the secret is removed, got %v", err)
+	}
+
+	ciphertext, _ = rotated.Encrypt("test", []byte("hello world"))
+	if _, err := retired.Decrypt("test", ciphertext); err != nil {
+		t.Errorf("expected the first secret to be used for encryption, got %v", err)
+	}
+}
+
+func TestNoSecrets(t *testing.T) {
+	if _, err := NewKeyring("", ""); !errors.Is(err, ErrNoSecrets) {
+		t.Errorf("expected ErrNoSecrets, got %v", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	k := mustKeyring(t, secret)
+	rotated := mustKeyring(t, otherSecret, secret)
+
+	signed := k.Sign("test", []byte("hello world"))
+	if message, err := rotated.Verify("test", signed); err != nil || string(message) != "hello world" {
+		t.Errorf("expected %q, got %q, %v", "hello world", message, err)
+	}
+
+	b := []byte(signed)
+	i := len(b) - 5
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	if _, err := k.Verify("test", string(b)); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected tampered value to fail verification, got %v", err)
+	}
+	if _, err := k.Verify("other", signed); err == nil {
+		t.Error("expected value not to verify for another purpose")
+	}
+	if _, err := mustKeyring(t, otherSecret).Verify("test", signed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+}
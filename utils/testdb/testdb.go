@@ -0,0 +1,40 @@
+// Package testdb opens throwaway databases for tests, with every migration
+// applied.
+package testdb
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/Gardego5/garrettdavis.dev/model/migrations"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open opens a new sqlite database, which is removed when the test ends.
+func Open(t testing.TB) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// names start with a timestamp, so they're in the order to apply them.
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range ups {
+		up, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(string(up)); err != nil {
+			t.Fatalf("applying %s: %v", name, err)
+		}
+	}
+	return db
+}
@@ -0,0 +1,32 @@
+// Package tracing is what's shared by everything that's traced. Spans are
+// started with the global tracer provider, so they cost nothing until it's
+// set up, by initialize.Tracing.
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport traces the requests it sends, and propagates their trace to the
+// server. Spans are named by the method and host, the full url is an
+// attribute.
+var Transport http.RoundTripper = otelhttp.NewTransport(http.DefaultTransport,
+	otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Host
+	}))
+
+// Client sends requests with Transport.
+var Client = &http.Client{Transport: Transport}
+
+// End ends span, recording err as its status if it isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}